@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+const breachedPasswordsUsage = `Uso: auth breached-passwords <comando> [opciones]
+
+Comandos:
+  import  --from <archivo|-> [--dir <directorio>] [--plain]
+  check   --password <contraseña> [--dir <directorio>]
+`
+
+// runBreachedPasswordsCommand mantiene la base local de contraseñas filtradas
+func runBreachedPasswordsCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, breachedPasswordsUsage)
+		return fmt.Errorf("falta el comando")
+	}
+	fs := flag.NewFlagSet("breached-passwords "+args[0], flag.ContinueOnError)
+	dir := fs.String("dir", service.BreachedPasswordsDir(), "directorio de la base (por defecto BREACHED_PASSWORDS_DIR)")
+	from := fs.String("from", "", "archivo con hashes SHA-1 \"HASH:APARICIONES\" o - para la entrada estándar")
+	plain := fs.Bool("plain", false, "el archivo contiene contraseñas en claro, una por línea")
+	password := fs.String("password", "", "contraseña a comprobar")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("--dir o BREACHED_PASSWORDS_DIR es obligatorio")
+	}
+
+	switch args[0] {
+	case "import":
+		if *from == "" {
+			return fmt.Errorf("--from es obligatorio")
+		}
+		var source io.Reader = os.Stdin
+		if *from != "-" {
+			f, err := os.Open(*from)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			source = f
+		}
+		imported, err := service.ImportBreachedPasswords(*dir, source, *plain)
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]interface{}{"imported": imported, "dir": *dir})
+
+	case "check":
+		count, err := service.BreachedPasswordCount(*dir, *password)
+		if err != nil {
+			return err
+		}
+		return printJSON(map[string]interface{}{"breached": count > 0, "count": count})
+
+	default:
+		fmt.Fprint(os.Stderr, breachedPasswordsUsage)
+		return fmt.Errorf("comando desconocido: %s", args[0])
+	}
+}
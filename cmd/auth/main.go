@@ -2,36 +2,210 @@ package main
 
 import (
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/authenticator"
 	"github.com/yourusername/api_ricky_and_morty/internal/auth/handler"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/notify"
 	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/metrics"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
 )
 
 func main() {
 	_ = godotenv.Load()
+	logger := middleware.NewLogger("auth")
+	slog.SetDefault(logger)
+
+	// Subcomando para gestionar cuentas de servicio sin levantar el servidor
+	if len(os.Args) > 1 && os.Args[1] == "service-account" {
+		if err := runServiceAccountCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	// Subcomando para actualizar la base de contraseñas filtradas
+	if len(os.Args) > 1 && os.Args[1] == "breached-passwords" {
+		if err := runBreachedPasswordsCommand(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	if err := service.InitDB(); err != nil {
 		log.Fatalf("No se pudo inicializar la base de datos: %v", err)
 	}
+	if adminUser := os.Getenv("ADMIN_USERNAME"); adminUser != "" {
+		generated, err := service.EnsureAdminUser(adminUser, os.Getenv("ADMIN_PASSWORD"))
+		if err != nil {
+			log.Fatalf("No se pudo crear el usuario administrador: %v", err)
+		}
+		if generated != "" {
+			// Solo se muestra al crear el administrador; guárdela y cámbiela
+			log.Printf("[AUTH] Administrador %s creado con la contraseña generada: %s", adminUser, generated)
+		}
+	}
+	// Backends de autenticación para el login
+	chain, err := authenticator.FromEnv()
+	if err != nil {
+		log.Fatalf("Configuración de autenticación inválida: %v", err)
+	}
+	handler.SetAuthenticator(chain)
+	log.Printf("[AUTH] Backends de autenticación: %s", chain.Name())
+
+	// Secreto compartido con el Gateway para /internal/refund y /internal/usage
+	internalSecret := os.Getenv("INTERNAL_AUTH_SECRET")
+	if internalSecret == "" {
+		log.Println("[AUTH] INTERNAL_AUTH_SECRET no configurado: los endpoints internos quedan deshabilitados")
+	}
+	handler.SetInternalSecret(internalSecret)
+
+	// Solo el Gateway y los proxies de confianza pueden indicar la IP del cliente
+	proxies, err := handler.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("TRUSTED_PROXIES inválido: %v", err)
+	}
+	handler.SetTrustedProxies(proxies)
+
+	// Notificaciones de seguridad y detección de logins anómalos
+	notifiers, err := notify.FromEnv()
+	if err != nil {
+		log.Fatalf("Configuración de notificaciones inválida: %v", err)
+	}
+	// Los códigos de step-up solo se entregan por destinos que llegan al usuario
+	if os.Getenv("LOGIN_ANOMALY_ACTION") == "step_up" && !notifiers.UserFacing() {
+		log.Fatalf("LOGIN_ANOMALY_ACTION=step_up requiere un notificador que llegue al usuario, como webhook")
+	}
+	handler.SetNotifier(notifiers)
+	// Sin base de contraseñas filtradas se sigue rechazando la lista incluida
+	if dir := service.BreachedPasswordsDir(); dir == "" {
+		log.Printf("[AUTH] BREACHED_PASSWORDS_DIR vacío: solo se rechazan las contraseñas de la lista incluida")
+	} else if _, err := os.Stat(dir); err != nil {
+		log.Printf("[AUTH] Advertencia: la base de contraseñas filtradas no está disponible, solo se usa la lista incluida: %v", err)
+	}
+	if path := os.Getenv("GEOIP_CSV"); path != "" {
+		if err := service.LoadGeoIP(path); err != nil {
+			log.Fatalf("Error cargando GEOIP_CSV: %v", err)
+		}
+	}
+
+	// Purga periódica de datos según la ventana de retención
+	interval := time.Hour
+	if minutes, err := strconv.Atoi(os.Getenv("RETENTION_INTERVAL_MINUTES")); err == nil && minutes > 0 {
+		interval = time.Duration(minutes) * time.Minute
+	}
+	service.StartRetentionJob(service.RetentionWindow(), interval)
+
+	// Rotación automática de los secretos de las cuentas de servicio, que se
+	// dejan en SERVICE_ACCOUNT_SECRETS_DIR para los procesos batch
+	if dir := service.ServiceAccountSecretsDir(); dir != "" {
+		maxAge := 90 * 24 * time.Hour
+		if days, err := strconv.Atoi(os.Getenv("SERVICE_ACCOUNT_MAX_AGE_DAYS")); err == nil && days > 0 {
+			maxAge = time.Duration(days) * 24 * time.Hour
+		}
+		grace := 24 * time.Hour
+		if hours, err := strconv.Atoi(os.Getenv("SERVICE_ACCOUNT_GRACE_HOURS")); err == nil && hours > 0 {
+			grace = time.Duration(hours) * time.Hour
+		}
+		rotationInterval := time.Hour
+		if minutes, err := strconv.Atoi(os.Getenv("SERVICE_ACCOUNT_ROTATION_INTERVAL_MINUTES")); err == nil && minutes > 0 {
+			rotationInterval = time.Duration(minutes) * time.Minute
+		}
+		service.StartServiceAccountRotationJob(dir, maxAge, grace, rotationInterval)
+	} else {
+		log.Printf("[AUTH] SERVICE_ACCOUNT_SECRETS_DIR vacío: los secretos de las cuentas de servicio solo se rotan a mano")
+	}
+
 	r := mux.NewRouter()
+	r.Use(middleware.Route)
+
+	// Métricas en formato Prometheus, en un puerto interno aparte
+	metricsPort := os.Getenv("METRICS_PORT")
+	if metricsPort == "" {
+		metricsPort = "9091"
+	}
+	go func() {
+		log.Printf("[AUTH] Metrics listening on port %s", metricsPort)
+		if err := metrics.Serve(":" + metricsPort); err != nil {
+			log.Fatalf("Error starting metrics server: %v", err)
+		}
+	}()
 
 	// Crear subrouter para api/v1
 	apiV1 := r.PathPrefix("/api/v1").Subrouter()
 
 	// Endpoints de autenticación bajo api/v1
 	apiV1.HandleFunc("/login", handler.LoginHandler).Methods("POST")
+	apiV1.HandleFunc("/login/verify", handler.LoginVerifyHandler).Methods("POST")
 	apiV1.HandleFunc("/validate", handler.ValidateTokenHandler).Methods("GET")
 	apiV1.HandleFunc("/register", handler.RegisterHandler).Methods("POST")
+	apiV1.HandleFunc("/logout", handler.LogoutHandler).Methods("POST")
+	apiV1.HandleFunc("/refresh", handler.RefreshHandler).Methods("POST")
+	apiV1.HandleFunc("/me/deactivate", handler.DeactivateSelfHandler).Methods("POST")
+	apiV1.HandleFunc("/me/password", handler.ChangePasswordHandler).Methods("POST")
+	apiV1.HandleFunc("/me/usage", handler.UsageHandler).Methods("GET")
+	apiV1.HandleFunc("/me/limits", handler.RateLimitsHandler).Methods("GET")
+	apiV1.HandleFunc("/internal/refund", handler.RefundHandler).Methods("POST")
+	apiV1.HandleFunc("/internal/usage", handler.UsageBatchHandler).Methods("POST")
+
+	// Cuentas de servicio (grant client_credentials)
+	apiV1.HandleFunc("/service-accounts/token", handler.ServiceAccountTokenHandler).Methods("POST")
+
+	// Organizaciones
+	apiV1.HandleFunc("/orgs", handler.CreateOrganizationHandler).Methods("POST")
+	apiV1.HandleFunc("/orgs/me", handler.MyOrganizationHandler).Methods("GET")
+	apiV1.HandleFunc("/orgs/{id:[0-9]+}/members", handler.AddMemberHandler).Methods("POST")
+	apiV1.HandleFunc("/orgs/{id:[0-9]+}/members/{username}", handler.RemoveMemberHandler).Methods("DELETE")
+	apiV1.HandleFunc("/orgs/me/leave", handler.LeaveOrganizationHandler).Methods("POST")
+	apiV1.HandleFunc("/me/org-invitations", handler.ListOrgInvitationsHandler).Methods("GET")
+	apiV1.HandleFunc("/me/org-invitations/{id:[0-9]+}/accept", handler.AcceptOrgInvitationHandler).Methods("POST")
+	apiV1.HandleFunc("/me/org-invitations/{id:[0-9]+}/decline", handler.DeclineOrgInvitationHandler).Methods("POST")
+
+	// Flujo de autorización de dispositivos (RFC 8628) para herramientas de terminal
+	apiV1.HandleFunc("/device/code", handler.DeviceCodeHandler).Methods("POST")
+	apiV1.HandleFunc("/device/token", handler.DeviceTokenHandler).Methods("POST")
+	r.HandleFunc("/device", handler.DeviceVerificationPageHandler).Methods("GET")
+	r.HandleFunc("/device", handler.DeviceVerificationHandler).Methods("POST")
+
+	// Endpoints de administración
+	admin := apiV1.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/invites", handler.CreateInviteHandler).Methods("POST")
+	admin.HandleFunc("/invites", handler.ListInvitesHandler).Methods("GET")
+	admin.HandleFunc("/invites/{code}", handler.RevokeInviteHandler).Methods("DELETE")
+	admin.HandleFunc("/users/{username}/deactivate", handler.DeactivateUserHandler).Methods("POST")
+	admin.HandleFunc("/users/{username}/reactivate", handler.ReactivateUserHandler).Methods("POST")
+	admin.HandleFunc("/retention/report", handler.RetentionReportHandler).Methods("GET")
+	admin.HandleFunc("/audit", handler.AuditLogHandler).Methods("GET")
+	admin.HandleFunc("/impersonate", handler.ImpersonateHandler).Methods("POST")
+	admin.HandleFunc("/orgs/{id:[0-9]+}/plan", handler.SetOrganizationPlanHandler).Methods("PUT")
+	admin.HandleFunc("/credits/topup", handler.TopUpHandler).Methods("POST")
+
+	// Aprovisionamiento SCIM 2.0 (RFC 7644) protegido con SCIM_BEARER_TOKEN
+	scim := r.PathPrefix("/scim/v2").Subrouter()
+	scim.Use(handler.RequireSCIMToken)
+	scim.HandleFunc("/Users", handler.SCIMListUsersHandler).Methods("GET")
+	scim.HandleFunc("/Users", handler.SCIMCreateUserHandler).Methods("POST")
+	scim.HandleFunc("/Users/{id}", handler.SCIMGetUserHandler).Methods("GET")
+	scim.HandleFunc("/Users/{id}", handler.SCIMPatchUserHandler).Methods("PATCH")
+	scim.HandleFunc("/Users/{id}", handler.SCIMDeleteUserHandler).Methods("DELETE")
+	scim.HandleFunc("/Groups", handler.SCIMListGroupsHandler).Methods("GET")
+	scim.HandleFunc("/Groups", handler.SCIMCreateGroupHandler).Methods("POST")
+	scim.HandleFunc("/Groups/{id}", handler.SCIMGetGroupHandler).Methods("GET")
+	scim.HandleFunc("/Groups/{id}", handler.SCIMPatchGroupHandler).Methods("PATCH")
+	scim.HandleFunc("/Groups/{id}", handler.SCIMDeleteGroupHandler).Methods("DELETE")
 
 	port := os.Getenv("AUTH_SERVICE_PORT")
 	if port == "" {
 		port = "8081"
 	}
 	log.Printf("[AUTH] Running on :%s", port)
-	h := cors.Default().Handler(r)
+	h := middleware.RequestID(middleware.AccessLog(logger, cors.Default().Handler(r)))
 	log.Fatal(http.ListenAndServe(":"+port, h))
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+const serviceAccountUsage = `Uso: auth service-account <comando> [opciones]
+
+Comandos:
+  create      --name <nombre> [--description <texto>] [--plan <plan>]
+  rotate      --name <nombre> [--grace 24h]
+  rotate-due  [--max-age 2160h] [--grace 24h]
+  list
+`
+
+// runServiceAccountCommand gestiona las cuentas de servicio desde la línea de
+// comandos. Los secretos nuevos se imprimen en JSON una sola vez.
+func runServiceAccountCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, serviceAccountUsage)
+		return fmt.Errorf("falta el comando")
+	}
+	if err := service.InitDB(); err != nil {
+		return fmt.Errorf("no se pudo inicializar la base de datos: %w", err)
+	}
+
+	fs := flag.NewFlagSet("service-account "+args[0], flag.ContinueOnError)
+	name := fs.String("name", "", "nombre de la cuenta de servicio")
+	description := fs.String("description", "", "descripción de la cuenta")
+	plan := fs.String("plan", "free", "plan de la cuenta")
+	grace := fs.Duration("grace", 24*time.Hour, "tiempo que siguen siendo válidos los secretos anteriores")
+	maxAge := fs.Duration("max-age", 90*24*time.Hour, "antigüedad a partir de la cual se rota el secreto")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		if *name == "" {
+			return fmt.Errorf("--name es obligatorio")
+		}
+		if !service.ValidPlan(*plan) {
+			return fmt.Errorf("plan inválido: %s", *plan)
+		}
+		account, secret, err := service.CreateServiceAccount(*name, *description, *plan)
+		if err != nil {
+			return err
+		}
+		if err := printJSON(map[string]interface{}{"client_id": account.Name, "client_secret": secret}); err != nil {
+			return err
+		}
+		return writeSecretFile(account.Name, secret)
+
+	case "rotate":
+		if *name == "" {
+			return fmt.Errorf("--name es obligatorio")
+		}
+		secret, err := service.RotateServiceAccountSecret(*name, *grace)
+		if err != nil {
+			return err
+		}
+		if err := printJSON(map[string]interface{}{"client_id": *name, "client_secret": secret, "previous_valid_for": grace.String()}); err != nil {
+			return err
+		}
+		return writeSecretFile(*name, secret)
+
+	case "rotate-due":
+		// La rotación periódica la hace el propio servicio si se define
+		// SERVICE_ACCOUNT_SECRETS_DIR; este comando la fuerza a mano
+		rotated := []map[string]interface{}{}
+		dir := service.ServiceAccountSecretsDir()
+		_, err := service.RotateDueServiceAccounts(*maxAge, *grace, func(name, secret string) error {
+			if dir != "" {
+				if err := service.WriteServiceAccountSecret(dir, name, secret); err != nil {
+					return err
+				}
+			}
+			rotated = append(rotated, map[string]interface{}{"client_id": name, "client_secret": secret})
+			return nil
+		})
+		if printErr := printJSON(rotated); printErr != nil {
+			return printErr
+		}
+		return err
+
+	case "list":
+		accounts, err := service.ListServiceAccounts()
+		if err != nil {
+			return err
+		}
+		return printJSON(accounts)
+
+	default:
+		fmt.Fprint(os.Stderr, serviceAccountUsage)
+		return fmt.Errorf("comando desconocido: %s", args[0])
+	}
+}
+
+// writeSecretFile deja el secreto en SERVICE_ACCOUNT_SECRETS_DIR, si está
+// definido, igual que la rotación automática
+func writeSecretFile(name, secret string) error {
+	if dir := service.ServiceAccountSecretsDir(); dir != "" {
+		return service.WriteServiceAccountSecret(dir, name, secret)
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
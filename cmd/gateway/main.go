@@ -2,17 +2,25 @@ package main
 
 import (
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
-	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 
 	"github.com/yourusername/api_ricky_and_morty/internal/gateway/handler"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/ratelimit"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/routes"
+	"github.com/yourusername/api_ricky_and_morty/internal/metrics"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
 )
 
 func main() {
+	// Logs estructurados en JSON, también para las líneas de log.Printf
+	logger := middleware.NewLogger("gateway")
+	slog.SetDefault(logger)
+
 	// Cargar variables de entorno
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
@@ -27,46 +35,82 @@ func main() {
 	if authPort == "" {
 		authPort = "8081"
 	}
-	rickMortyPort := os.Getenv("RICKMORTY_SERVICE_PORT")
-	if rickMortyPort == "" {
-		rickMortyPort = "8082"
+	rickmortyPort := os.Getenv("RICKMORTY_SERVICE_PORT")
+	if rickmortyPort == "" {
+		rickmortyPort = "8082"
+	}
+	// Los upstreams de la tabla de rutas usan los puertos con sus valores por
+	// defecto; el resto de variables se leen del entorno
+	upstreamVars := map[string]string{
+		"AUTH_SERVICE_PORT":      authPort,
+		"RICKMORTY_SERVICE_PORT": rickmortyPort,
+	}
+	lookup := func(name string) string {
+		if value, ok := upstreamVars[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
 	}
 
-	// Crear el router
-	router := mux.NewRouter()
-
-	// Crear el handler
-	gatewayHandler := handler.NewGatewayHandler(authPort, rickMortyPort)
-
-	// Configurar rutas
-	api := router.PathPrefix("/api/v1").Subrouter()
+	// Cargar la tabla de rutas
+	routesFile := os.Getenv("GATEWAY_ROUTES_FILE")
+	if routesFile == "" {
+		routesFile = "config/routes.json"
+	}
+	table, err := routes.Load(routesFile, lookup)
+	if err != nil {
+		log.Fatalf("Error cargando la tabla de rutas: %v", err)
+	}
+	log.Printf("Loaded %d routes from %s", len(table.Routes), routesFile)
 
-	// Rutas de personajes
-	api.HandleFunc("/characters", gatewayHandler.GetCharacters).Methods("GET")
-	api.HandleFunc("/character", gatewayHandler.GetCharacter).Methods("GET")
-	api.HandleFunc("/character/{id}", gatewayHandler.GetCharacter).Methods("GET")
+	// Crear el handler y el router a partir de la tabla
+	gatewayHandler := handler.NewGatewayHandler(authPort, table)
 
-	// Rutas de ubicaciones
-	api.HandleFunc("/locations", gatewayHandler.GetLocations).Methods("GET")
-	api.HandleFunc("/location", gatewayHandler.GetLocation).Methods("GET")
-	api.HandleFunc("/location/{id}", gatewayHandler.GetLocation).Methods("GET")
+	// Con GATEWAY_RATE_LIMIT_REDIS_URL los límites de tasa se comparten entre
+	// réplicas; si no, cada Gateway los lleva en memoria
+	if redisURL := os.Getenv("GATEWAY_RATE_LIMIT_REDIS_URL"); redisURL != "" {
+		store, err := ratelimit.NewRESPStore(redisURL)
+		if err != nil {
+			log.Fatalf("Error configurando el limitador de tasa: %v", err)
+		}
+		gatewayHandler.SetRateLimitStore(store)
+		log.Printf("Rate limits stored in Redis")
+	}
+	router := gatewayHandler.Router()
+	router.Use(middleware.Route)
 
-	// Rutas de episodios
-	api.HandleFunc("/episodes", gatewayHandler.GetEpisodes).Methods("GET")
-	api.HandleFunc("/episode", gatewayHandler.GetEpisode).Methods("GET")
-	api.HandleFunc("/episode/{id}", gatewayHandler.GetEpisode).Methods("GET")
+	// Métricas en formato Prometheus, en un puerto interno aparte
+	metricsPort := os.Getenv("METRICS_PORT")
+	if metricsPort == "" {
+		metricsPort = "9090"
+	}
+	go func() {
+		log.Printf("Metrics listening on port %s", metricsPort)
+		if err := metrics.Serve(":" + metricsPort); err != nil {
+			log.Fatalf("Error starting metrics server: %v", err)
+		}
+	}()
 
-	// Configurar CORS
+	// Configurar CORS. Se exponen las cabeceras de las ventanas de uso del
+	// servicio de autenticación, las del limitador de tasa y las de la caché.
+	exposedHeaders := []string{
+		"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-RateLimit-Window", "Retry-After",
+		"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+		"ETag", "Age", "X-Cache", "X-Request-ID",
+	}
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Client-Thumbprint", "If-None-Match", "Cache-Control", "X-Request-ID"},
+		ExposedHeaders:   exposedHeaders,
 		AllowCredentials: true,
 	}).Handler(router)
+	// Cada petición recibe un X-Request-ID y deja una línea en el log de acceso
+	server := middleware.RequestID(middleware.AccessLog(logger, corsHandler))
 
 	// Iniciar el servidor
 	log.Printf("Gateway service starting on port %s", gatewayPort)
-	if err := http.ListenAndServe(":"+gatewayPort, corsHandler); err != nil {
+	if err := http.ListenAndServe(":"+gatewayPort, server); err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -9,10 +10,16 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 
+	"github.com/yourusername/api_ricky_and_morty/internal/metrics"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
 	"github.com/yourusername/api_ricky_and_morty/internal/rickmorty/handler"
 )
 
 func main() {
+	// Logs estructurados en JSON, también para las líneas de log.Printf
+	logger := middleware.NewLogger("rickmorty")
+	slog.SetDefault(logger)
+
 	// Cargar variables de entorno
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
@@ -26,6 +33,19 @@ func main() {
 
 	// Crear el router
 	router := mux.NewRouter()
+	router.Use(middleware.Route)
+
+	// Métricas en formato Prometheus, en un puerto interno aparte
+	metricsPort := os.Getenv("METRICS_PORT")
+	if metricsPort == "" {
+		metricsPort = "9092"
+	}
+	go func() {
+		log.Printf("Metrics listening on port %s", metricsPort)
+		if err := metrics.Serve(":" + metricsPort); err != nil {
+			log.Fatalf("Error starting metrics server: %v", err)
+		}
+	}()
 
 	// Crear el handler
 	rickMortyHandler := handler.NewRickMortyHandler("https://rickandmortyapi.com/api")
@@ -52,13 +72,15 @@ func main() {
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "X-Internal-Auth"},
+		AllowedHeaders:   []string{"Content-Type", "X-Internal-Auth", "X-Request-ID"},
 		AllowCredentials: true,
 	}).Handler(router)
+	// Cada petición recibe un X-Request-ID y deja una línea en el log de acceso
+	server := middleware.RequestID(middleware.AccessLog(logger, corsHandler))
 
 	// Iniciar el servidor
 	log.Printf("Rick and Morty service starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, corsHandler); err != nil {
+	if err := http.ListenAndServe(":"+port, server); err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
 }
@@ -0,0 +1,94 @@
+package authenticator
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCredentials indica que el backend rechazó usuario o contraseña
+	ErrInvalidCredentials = errors.New("usuario o contraseña incorrectos")
+	// ErrUnavailable indica que ningún backend pudo verificar las credenciales
+	ErrUnavailable = errors.New("ningún backend de autenticación disponible")
+)
+
+// Identity es el resultado de una autenticación exitosa
+type Identity struct {
+	Username string
+	Source   string
+}
+
+// Authenticator verifica credenciales contra un backend concreto
+type Authenticator interface {
+	Name() string
+	Authenticate(username, password string) (*Identity, error)
+}
+
+// Chain prueba cada autenticador en orden y devuelve el primer éxito
+type Chain []Authenticator
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, a := range c {
+		names[i] = a.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Authenticate devuelve ErrInvalidCredentials si algún backend rechazó las
+// credenciales, o ErrUnavailable si todos fallaron por errores internos.
+func (c Chain) Authenticate(username, password string) (*Identity, error) {
+	rejected := false
+	for _, a := range c {
+		identity, err := a.Authenticate(username, password)
+		if err == nil {
+			return identity, nil
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			rejected = true
+			continue
+		}
+		log.Printf("[AUTH] Error en el backend %s: %v", a.Name(), err)
+	}
+	if rejected || len(c) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	return nil, ErrUnavailable
+}
+
+// FromEnv construye la cadena de autenticadores a partir de AUTH_BACKENDS
+// (por defecto "local"). Los backends se configuran con sus propias variables.
+func FromEnv() (Chain, error) {
+	backends := os.Getenv("AUTH_BACKENDS")
+	if backends == "" {
+		backends = "local"
+	}
+	var chain Chain
+	for _, name := range strings.Split(backends, ",") {
+		switch strings.TrimSpace(name) {
+		case "local":
+			chain = append(chain, Local{})
+		case "ldap":
+			url := os.Getenv("LDAP_URL")
+			template := os.Getenv("LDAP_BIND_DN")
+			if url == "" || template == "" {
+				return nil, errors.New("LDAP_URL y LDAP_BIND_DN son obligatorios para el backend ldap")
+			}
+			chain = append(chain, &LDAP{URL: url, BindDNTemplate: template, Timeout: 5 * time.Second})
+		case "htpasswd":
+			path := os.Getenv("HTPASSWD_FILE")
+			if path == "" {
+				return nil, errors.New("HTPASSWD_FILE es obligatorio para el backend htpasswd")
+			}
+			chain = append(chain, &Htpasswd{Path: path})
+		case "":
+		default:
+			return nil, fmt.Errorf("backend de autenticación desconocido: %s", name)
+		}
+	}
+	return chain, nil
+}
@@ -0,0 +1,68 @@
+package authenticator
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Htpasswd verifica credenciales contra un archivo estilo htpasswd de Apache.
+// Se admiten hashes bcrypt ($2y$, $2a$, $2b$) y {SHA}. El archivo se lee en
+// cada intento para que los cambios se apliquen sin reiniciar el servicio.
+type Htpasswd struct {
+	Path string
+}
+
+func (h *Htpasswd) Name() string { return "htpasswd" }
+
+func (h *Htpasswd) Authenticate(username, password string) (*Identity, error) {
+	hash, err := h.lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	if hash == "" || !checkHtpasswdHash(hash, password) {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: username, Source: h.Name()}, nil
+}
+
+// lookup devuelve el hash del usuario o una cadena vacía si no existe
+func (h *Htpasswd) lookup(username string) (string, error) {
+	file, err := os.Open(h.Path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if ok && user == username {
+			return hash, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+func checkHtpasswdHash(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(hash, "{SHA}")), []byte(expected)) == 1
+	default:
+		// MD5 (apr1), crypt y texto plano no están soportados
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package authenticator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHtpasswdAuthenticate(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("wubba"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	content := "# usuarios\n" +
+		"rick:" + string(hash) + "\n" +
+		// {SHA} de "morty"
+		"morty:{SHA}HbDtOO60uQCvEsgxBeE7rzfnQC8=\n" +
+		"plain:secreto\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h := &Htpasswd{Path: path}
+
+	tests := []struct {
+		username, password string
+		wantErr            error
+	}{
+		{"rick", "wubba", nil},
+		{"rick", "lubba", ErrInvalidCredentials},
+		{"morty", "morty", nil},
+		{"morty", "aw-jeez", ErrInvalidCredentials},
+		{"plain", "secreto", ErrInvalidCredentials},
+		{"nadie", "x", ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		identity, err := h.Authenticate(tt.username, tt.password)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s/%s: error = %v, se esperaba %v", tt.username, tt.password, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (identity.Username != tt.username || identity.Source != "htpasswd") {
+			t.Errorf("%s: identidad = %+v", tt.username, identity)
+		}
+	}
+}
+
+func TestChainAuthenticate(t *testing.T) {
+	unavailable := &Htpasswd{Path: filepath.Join(t.TempDir(), "no-existe")}
+	if _, err := (Chain{unavailable}).Authenticate("rick", "x"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("sin backends disponibles: error = %v", err)
+	}
+	if _, err := (Chain{}).Authenticate("rick", "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("cadena vacía: error = %v", err)
+	}
+}
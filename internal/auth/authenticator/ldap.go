@@ -0,0 +1,240 @@
+package authenticator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxBERLength limita el tamaño de un elemento de la respuesta. Un
+// BindResponse ocupa unos pocos bytes; el límite evita que un servidor hostil
+// o averiado obligue a reservar hasta 4 GiB con una longitud falsa.
+const maxBERLength = 64 << 10
+
+// Códigos de resultado LDAP (RFC 4511) que se tratan de forma especial
+const (
+	ldapSuccess            = 0
+	ldapInvalidCredentials = 49
+)
+
+// LDAP verifica credenciales haciendo un bind simple contra el directorio.
+// BindDNTemplate contiene un %s que se sustituye por el usuario escapado,
+// por ejemplo "uid=%s,ou=people,dc=example,dc=org".
+type LDAP struct {
+	URL            string
+	BindDNTemplate string
+	Timeout        time.Duration
+	TLSConfig      *tls.Config
+}
+
+func (l *LDAP) Name() string { return "ldap" }
+
+func (l *LDAP) Authenticate(username, password string) (*Identity, error) {
+	// Un bind con contraseña vacía es un bind anónimo y el servidor lo acepta
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if l.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(l.Timeout))
+	}
+
+	dn := fmt.Sprintf(l.BindDNTemplate, escapeDN(username))
+	if _, err := conn.Write(encodeBindRequest(1, dn, password)); err != nil {
+		return nil, err
+	}
+	code, err := readBindResponse(bufio.NewReader(conn))
+	// Cerrar la sesión de forma ordenada; el resultado no importa
+	conn.Write(encodeUnbindRequest(2))
+	if err != nil {
+		return nil, err
+	}
+
+	switch code {
+	case ldapSuccess:
+		return &Identity{Username: username, Source: l.Name()}, nil
+	case ldapInvalidCredentials:
+		return nil, ErrInvalidCredentials
+	default:
+		return nil, fmt.Errorf("bind LDAP falló con código %d", code)
+	}
+}
+
+func (l *LDAP) dial() (net.Conn, error) {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: l.Timeout}
+	switch u.Scheme {
+	case "ldap":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		return dialer.Dial("tcp", host)
+	case "ldaps":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		config := l.TLSConfig
+		if config == nil {
+			config = &tls.Config{ServerName: u.Hostname()}
+		}
+		return tls.DialWithDialer(dialer, "tcp", host, config)
+	default:
+		return nil, fmt.Errorf("esquema LDAP no soportado: %s", u.Scheme)
+	}
+}
+
+// escapeDN escapa un valor de atributo para incluirlo en un DN (RFC 4514)
+func escapeDN(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case r == '\\' || r == ',' || r == '+' || r == '"' || r == '<' || r == '>' || r == ';' || r == '=':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == 0:
+			b.WriteString(`\00`)
+		case (r == ' ' || r == '#') && i == 0:
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == ' ' && i == len(value)-1:
+			b.WriteString(`\ `)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Codificación BER mínima para los mensajes Bind y Unbind
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var out []byte
+	for n > 0 {
+		out = append([]byte{byte(n)}, out...)
+		n >>= 8
+	}
+	return append([]byte{0x80 | byte(len(out))}, out...)
+}
+
+func berElement(tag byte, content []byte) []byte {
+	out := append([]byte{tag}, berLength(len(content))...)
+	return append(out, content...)
+}
+
+func berInteger(n int) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(n)}, content...)
+		n >>= 8
+		if n == 0 && content[0] < 0x80 {
+			break
+		}
+	}
+	return berElement(0x02, content)
+}
+
+func encodeBindRequest(messageID int, dn, password string) []byte {
+	var bind []byte
+	bind = append(bind, berInteger(3)...)
+	bind = append(bind, berElement(0x04, []byte(dn))...)
+	bind = append(bind, berElement(0x80, []byte(password))...)
+
+	var msg []byte
+	msg = append(msg, berInteger(messageID)...)
+	msg = append(msg, berElement(0x60, bind)...)
+	return berElement(0x30, msg)
+}
+
+func encodeUnbindRequest(messageID int) []byte {
+	msg := append(berInteger(messageID), 0x42, 0x00)
+	return berElement(0x30, msg)
+}
+
+// readElement lee un elemento BER y devuelve su tag y contenido
+func readElement(r *bufio.Reader) (byte, []byte, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		size := int(first & 0x7f)
+		if size == 0 || size > 4 {
+			return 0, nil, errors.New("longitud BER no soportada")
+		}
+		length = 0
+		for i := 0; i < size; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxBERLength {
+		return 0, nil, fmt.Errorf("elemento BER demasiado grande: %d bytes", length)
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return 0, nil, err
+	}
+	return tag, content, nil
+}
+
+// readBindResponse devuelve el resultCode de un BindResponse
+func readBindResponse(r *bufio.Reader) (int, error) {
+	tag, msg, err := readElement(r)
+	if err != nil {
+		return 0, err
+	}
+	if tag != 0x30 {
+		return 0, errors.New("respuesta LDAP inválida")
+	}
+	body := bufio.NewReader(bytes.NewReader(msg))
+	if _, _, err := readElement(body); err != nil { // messageID
+		return 0, err
+	}
+	tag, op, err := readElement(body)
+	if err != nil {
+		return 0, err
+	}
+	if tag != 0x61 {
+		return 0, fmt.Errorf("operación LDAP inesperada: 0x%x", tag)
+	}
+	tag, code, err := readElement(bufio.NewReader(bytes.NewReader(op)))
+	if err != nil {
+		return 0, err
+	}
+	if tag != 0x0a || len(code) == 0 {
+		return 0, errors.New("resultCode LDAP inválido")
+	}
+	result := 0
+	for _, b := range code {
+		result = result<<8 | int(b)
+	}
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package authenticator
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLDAP atiende un bind por conexión. respond recibe el DN y la contraseña
+// del BindRequest y devuelve los bytes que se envían como respuesta.
+func fakeLDAP(t *testing.T, respond func(dn, password string) []byte) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				dn, password, err := readBindRequest(bufio.NewReader(conn))
+				if err != nil {
+					t.Errorf("BindRequest inválido: %v", err)
+					return
+				}
+				conn.Write(respond(dn, password))
+			}(conn)
+		}
+	}()
+	return "ldap://" + listener.Addr().String()
+}
+
+// readBindRequest decodifica el BindRequest simple que envía el cliente
+func readBindRequest(r *bufio.Reader) (string, string, error) {
+	tag, msg, err := readElement(r)
+	if err != nil {
+		return "", "", err
+	}
+	if tag != 0x30 {
+		return "", "", errors.New("se esperaba un SEQUENCE")
+	}
+	body := bufio.NewReader(bytes.NewReader(msg))
+	if _, _, err := readElement(body); err != nil {
+		return "", "", err
+	}
+	tag, op, err := readElement(body)
+	if err != nil {
+		return "", "", err
+	}
+	if tag != 0x60 {
+		return "", "", errors.New("se esperaba un BindRequest")
+	}
+	fields := bufio.NewReader(bytes.NewReader(op))
+	if _, _, err := readElement(fields); err != nil { // versión
+		return "", "", err
+	}
+	_, dn, err := readElement(fields)
+	if err != nil {
+		return "", "", err
+	}
+	_, password, err := readElement(fields)
+	if err != nil {
+		return "", "", err
+	}
+	return string(dn), string(password), nil
+}
+
+// bindResponse codifica un BindResponse con el resultCode dado
+func bindResponse(code byte) []byte {
+	var result []byte
+	result = append(result, berElement(0x0a, []byte{code})...)
+	result = append(result, berElement(0x04, nil)...)
+	result = append(result, berElement(0x04, nil)...)
+	msg := append(berInteger(1), berElement(0x61, result)...)
+	return berElement(0x30, msg)
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	url := fakeLDAP(t, func(dn, password string) []byte {
+		switch {
+		case dn == "uid=rick,ou=people,dc=example,dc=org" && password == "wubba":
+			return bindResponse(ldapSuccess)
+		case strings.HasPrefix(dn, "uid=broken"):
+			return []byte{0x31, 0x03, 0x02, 0x01, 0x01}
+		case strings.HasPrefix(dn, "uid=huge"):
+			// SEQUENCE que anuncia casi 2 GiB de contenido
+			return []byte{0x30, 0x84, 0x7f, 0xff, 0xff, 0xff}
+		case strings.HasPrefix(dn, "uid=truncated"):
+			return bindResponse(ldapSuccess)[:5]
+		case strings.HasPrefix(dn, "uid=busy"):
+			return bindResponse(51)
+		default:
+			return bindResponse(ldapInvalidCredentials)
+		}
+	})
+	l := &LDAP{URL: url, BindDNTemplate: "uid=%s,ou=people,dc=example,dc=org", Timeout: 2 * time.Second}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+		anyErr   bool
+	}{
+		{name: "bind correcto", username: "rick", password: "wubba"},
+		{name: "contraseña incorrecta", username: "rick", password: "lubba", wantErr: ErrInvalidCredentials},
+		{name: "contraseña vacía no hace bind anónimo", username: "rick", password: "", wantErr: ErrInvalidCredentials},
+		{name: "respuesta con tag inválido", username: "broken", password: "x", anyErr: true},
+		{name: "longitud excesiva", username: "huge", password: "x", anyErr: true},
+		{name: "respuesta truncada", username: "truncated", password: "x", anyErr: true},
+		{name: "otro resultCode", username: "busy", password: "x", anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := l.Authenticate(tt.username, tt.password)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil || errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("error = %v, se esperaba un error del servidor", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("error inesperado: %v", err)
+				}
+				if identity.Username != tt.username || identity.Source != "ldap" {
+					t.Fatalf("identidad = %+v", identity)
+				}
+			}
+		})
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"rick", "rick"},
+		{"a,b", `a\,b`},
+		{"x=y+z", `x\=y\+z`},
+		{" lead", `\ lead`},
+		{"#hash", `\#hash`},
+		{"trail ", `trail\ `},
+		{"nul\x00", `nul\00`},
+	}
+	for _, tt := range tests {
+		if got := escapeDN(tt.in); got != tt.want {
+			t.Errorf("escapeDN(%q) = %q, se esperaba %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadElementLongForm(t *testing.T) {
+	content := bytes.Repeat([]byte{'a'}, 300)
+	tag, got, err := readElement(bufio.NewReader(bytes.NewReader(berElement(0x04, content))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != 0x04 || !bytes.Equal(got, content) {
+		t.Fatalf("tag = 0x%x, %d bytes", tag, len(got))
+	}
+}
@@ -0,0 +1,33 @@
+package authenticator
+
+import (
+	"errors"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Local verifica las contraseñas bcrypt de la tabla de usuarios
+type Local struct{}
+
+func (Local) Name() string { return models.SourceLocal }
+
+func (Local) Authenticate(username, password string) (*Identity, error) {
+	user, err := service.GetUserByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Los usuarios aprovisionados desde otro backend no tienen contraseña local
+	if user.Source != models.SourceLocal {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: user.Username, Source: models.SourceLocal}, nil
+}
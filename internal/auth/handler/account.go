@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"gorm.io/gorm"
+)
+
+// DeactivateSelfHandler permite al usuario desactivar su propia cuenta
+func DeactivateSelfHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	if rejectImpersonation(w, claims) {
+		return
+	}
+	username := claims.Subject
+	err = service.DeactivateUser(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// El usuario ya no existe o ya estaba desactivado: el token no sirve
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado o ya desactivado", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error desactivando la cuenta", nil)
+		return
+	}
+	service.RecordAudit(username, service.AuditDeactivate, "self", originalClientIP(r))
+	clearAuthCookie(w)
+	sendJSONResponse(w, http.StatusOK, "success", "Cuenta desactivada", nil)
+}
+
+// DeactivateUserHandler desactiva la cuenta de otro usuario (solo administradores)
+func DeactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	username := mux.Vars(r)["username"]
+	err := service.DeactivateUser(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado o ya desactivado", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error desactivando la cuenta", nil)
+		return
+	}
+	admin := claims.Subject
+	service.RecordAudit(username, service.AuditDeactivate, "by:"+admin, originalClientIP(r))
+	sendJSONResponse(w, http.StatusOK, "success", "Cuenta desactivada", nil)
+}
+
+// ReactivateUserHandler reactiva una cuenta desactivada (solo administradores)
+func ReactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	username := mux.Vars(r)["username"]
+	err := service.ReactivateUser(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado o no desactivado", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error reactivando la cuenta", nil)
+		return
+	}
+	admin := claims.Subject
+	service.RecordAudit(username, service.AuditReactivate, "by:"+admin, originalClientIP(r))
+	sendJSONResponse(w, http.StatusOK, "success", "Cuenta reactivada", nil)
+}
+
+// RetentionReportHandler muestra lo que eliminaría la próxima purga sin borrar nada
+func RetentionReportHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	report, err := service.Purge(service.RetentionWindow(), true)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error generando el reporte de retención", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Reporte de retención (simulación)", report)
+}
+
+// AuditLogHandler lista los eventos de auditoría (solo administradores)
+func AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	events, err := service.ListAuditEvents(r.URL.Query().Get("username"), limit)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo la auditoría", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Eventos de auditoría obtenidos", events)
+}
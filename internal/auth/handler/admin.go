@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
+)
+
+// parseTokenFromRequest lee el token de la petición y verifica sus claims sin
+// consumir usos del token.
+func parseTokenFromRequest(r *http.Request) (*tokens.Claims, error) {
+	tokenString, ok := tokenFromRequest(r)
+	if !ok {
+		return nil, errors.New("token no encontrado en cookie")
+	}
+	config := tokens.ConfigFromEnv()
+	if len(config.Secret) == 0 {
+		return nil, errors.New("JWT secret not set")
+	}
+	claims, err := config.Parse(tokenString)
+	if err != nil {
+		return nil, errors.New("token inválido")
+	}
+	if !service.TokenExists(tokenString) {
+		return nil, errors.New("token expirado")
+	}
+	middleware.SetUser(r.Context(), claims.Subject)
+	return claims, nil
+}
+
+// requireAdmin verifica que la petición venga de un usuario administrador.
+// Si no es así, escribe la respuesta de error y devuelve false.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*tokens.Claims, bool) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return nil, false
+	}
+	if claims.Role != models.RoleAdmin {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Se requiere rol de administrador", nil)
+		return nil, false
+	}
+	return claims, true
+}
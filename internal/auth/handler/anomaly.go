@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/notify"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
+)
+
+// notifier entrega los avisos de seguridad a los usuarios
+var notifier notify.Notifier = notify.Multi{notify.Log{}}
+
+// SetNotifier configura los destinos de las notificaciones
+func SetNotifier(n notify.Notifier) {
+	notifier = n
+}
+
+// stepUpRequired indica si un login anómalo exige un segundo factor
+// (LOGIN_ANOMALY_ACTION=step_up) o solo se notifica (notify, por defecto)
+func stepUpRequired() bool {
+	return os.Getenv("LOGIN_ANOMALY_ACTION") == "step_up"
+}
+
+// loginSignal identifica el dispositivo por la clave del cliente si la envía,
+// o por su User-Agent
+func loginSignal(r *http.Request, username, keyThumbprint string) service.LoginSignal {
+	source := "ua:" + r.UserAgent()
+	if keyThumbprint != "" {
+		source = "key:" + keyThumbprint
+	}
+	sum := sha256.Sum256([]byte(source))
+	return service.LoginSignal{
+		Username:    username,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		IP:          originalClientIP(r),
+		At:          time.Now(),
+	}
+}
+
+// notifyAsync envía la notificación sin bloquear la petición
+func notifyAsync(n notify.Notification) {
+	n.SentAt = time.Now()
+	go func() {
+		if err := notifier.Notify(n); err != nil {
+			log.Printf("[AUTH] No se pudo notificar a %s: %v", n.Username, err)
+		}
+	}()
+}
+
+// checkLoginAnomalies evalúa el login y devuelve false si ya respondió, ya sea
+// por un error o porque el usuario debe completar un segundo factor
+func checkLoginAnomalies(w http.ResponseWriter, r *http.Request, signal service.LoginSignal) bool {
+	anomalies, err := service.AssessLogin(signal)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error evaluando el login", nil)
+		return false
+	}
+	if len(anomalies) == 0 {
+		return true
+	}
+	detail := strings.Join(anomalies, ",")
+	service.RecordAudit(signal.Username, service.AuditLoginAnomaly, detail, signal.IP)
+	fields := map[string]string{"ip": signal.IP, "user_agent": r.UserAgent(), "anomalies": detail}
+
+	if !stepUpRequired() {
+		notifyAsync(notify.Notification{
+			Username: signal.Username,
+			Kind:     notify.KindNewDevice,
+			Message:  "Nuevo inicio de sesión desde un dispositivo o ubicación desconocidos",
+			Fields:   fields,
+		})
+		return true
+	}
+
+	challengeID, code, err := service.CreateLoginChallenge(signal)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error creando el desafío", nil)
+		return false
+	}
+	// El código se envía de forma síncrona: sin él el usuario no puede continuar
+	err = notifier.Notify(notify.Notification{
+		Username: signal.Username,
+		Kind:     notify.KindStepUpCode,
+		Message:  "Código para confirmar el inicio de sesión",
+		Fields:   fields,
+		Code:     code,
+		SentAt:   time.Now(),
+	})
+	if err != nil {
+		sendJSONResponse(w, http.StatusServiceUnavailable, "error", "No se pudo enviar el código de verificación", nil)
+		return false
+	}
+	sendJSONResponse(w, http.StatusUnauthorized, "error", "Se requiere verificación adicional", map[string]interface{}{
+		"error_code":   "step_up_required",
+		"challenge_id": challengeID,
+		"anomalies":    anomalies,
+	})
+	return false
+}
+
+// completeLogin recuerda el dispositivo, emite el token y lo guarda en la cookie
+func completeLogin(w http.ResponseWriter, r *http.Request, user *models.User, signal service.LoginSignal, keyThumbprint, detail string) {
+	if err := service.RememberDevice(signal); err != nil {
+		log.Printf("[AUTH] Error registrando el dispositivo de %s: %v", user.Username, err)
+	}
+	tokenString, err := issueToken(user, loginBinding(r, keyThumbprint))
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", err.Error(), nil)
+		return
+	}
+	service.RecordAudit(user.Username, service.AuditLogin, detail, originalClientIP(r))
+	middleware.SetUser(r.Context(), user.Username)
+	setAuthCookie(w, tokenString)
+	sendJSONResponse(w, http.StatusOK, "success", "Login exitoso", map[string]interface{}{
+		"username": user.Username,
+		"message":  "Token guardado en cookie",
+	})
+}
+
+// LoginVerifyHandler completa un login anómalo con el código enviado al usuario
+func LoginVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeID   string `json:"challenge_id"`
+		Code          string `json:"code"`
+		KeyThumbprint string `json:"key_thumbprint"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	challenge, err := service.VerifyLoginChallenge(req.ChallengeID, req.Code)
+	if errors.Is(err, service.ErrChallengeInvalid) || errors.Is(err, service.ErrChallengeExhausted) {
+		service.RecordAudit("", service.AuditStepUpFailed, "challenge:"+req.ChallengeID, originalClientIP(r))
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error verificando el código", nil)
+		return
+	}
+	user, err := service.GetUserByUsername(challenge.Username)
+	if err != nil || !user.Active() {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Cuenta desactivada", nil)
+		return
+	}
+	service.RecordAudit(user.Username, service.AuditStepUpVerified, "", originalClientIP(r))
+	// Se recuerda el dispositivo que originó el desafío, no el que lo completa
+	signal := service.LoginSignal{Username: user.Username, Fingerprint: challenge.Fingerprint, IP: challenge.IP, At: time.Now()}
+	completeLogin(w, r, user, signal, req.KeyThumbprint, "step_up")
+}
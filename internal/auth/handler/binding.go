@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Características del cliente a las que se puede ligar un token
+const (
+	bindIP  = "ip"
+	bindUA  = "ua"
+	bindKey = "key"
+)
+
+// tokenBinding describe a qué características del cliente queda ligado un token
+type tokenBinding struct {
+	Modes       string
+	Fingerprint string
+}
+
+// configuredBindingModes lee TOKEN_BINDING, por ejemplo "ip,ua". Vacío desactiva el ligado.
+func configuredBindingModes() []string {
+	var modes []string
+	for _, mode := range strings.Split(os.Getenv("TOKEN_BINDING"), ",") {
+		switch mode = strings.TrimSpace(strings.ToLower(mode)); mode {
+		case bindIP, bindUA:
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
+// ipPrefix reduce la IP a su red (/24 en IPv4, /64 en IPv6) para tolerar
+// cambios de dirección dentro de la misma red
+func ipPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String()
+}
+
+// computeBinding calcula la huella del cliente para los modos indicados
+func computeBinding(r *http.Request, modes []string, thumbprint string) tokenBinding {
+	if len(modes) == 0 {
+		return tokenBinding{}
+	}
+	var parts []string
+	for _, mode := range modes {
+		switch mode {
+		case bindIP:
+			parts = append(parts, bindIP+"="+ipPrefix(originalClientIP(r)))
+		case bindUA:
+			parts = append(parts, bindUA+"="+r.UserAgent())
+		case bindKey:
+			parts = append(parts, bindKey+"="+thumbprint)
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return tokenBinding{Modes: strings.Join(modes, ","), Fingerprint: hex.EncodeToString(sum[:])}
+}
+
+// loginBinding calcula el ligado de un token nuevo. El cliente puede aportar
+// la huella de su clave, que luego debe enviar en X-Client-Thumbprint. La
+// huella es solo un valor que el cliente repite en cada petición: no prueba
+// que posea la clave, así que quien robe el token y la huella puede usarlos.
+// Dificulta la reutilización, pero no sustituye a una prueba de posesión
+// como DPoP o mTLS.
+func loginBinding(r *http.Request, thumbprint string) tokenBinding {
+	modes := configuredBindingModes()
+	if thumbprint != "" {
+		modes = append(modes, bindKey)
+	}
+	return computeBinding(r, modes, thumbprint)
+}
+
+// requestBinding recalcula la huella de la petición actual con los modos con
+// los que se emitió el token
+func requestBinding(r *http.Request, modes string) tokenBinding {
+	if modes == "" {
+		return tokenBinding{}
+	}
+	return computeBinding(r, strings.Split(modes, ","), r.Header.Get("X-Client-Thumbprint"))
+}
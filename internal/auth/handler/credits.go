@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+// parseDateParam acepta fechas como 2006-01-02 o en formato RFC 3339
+func parseDateParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UsageHandler devuelve el extracto de créditos del usuario, con filtros
+// opcionales from (inclusive) y to (exclusivo)
+func UsageHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	from, err := parseDateParam(r.URL.Query().Get("from"))
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Fecha 'from' inválida, use YYYY-MM-DD o RFC 3339", nil)
+		return
+	}
+	to, err := parseDateParam(r.URL.Query().Get("to"))
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Fecha 'to' inválida, use YYYY-MM-DD o RFC 3339", nil)
+		return
+	}
+	username := claims.Subject
+	statement, err := service.GetStatement(username, from, to)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo el extracto", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Extracto de créditos", statement)
+}
+
+// TopUpHandler agrega créditos a un usuario (solo administradores)
+func TopUpHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Username    string `json:"username"`
+		Amount      int    `json:"amount"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	if req.Amount <= 0 {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "El importe debe ser positivo", nil)
+		return
+	}
+	if _, err := service.GetUserByUsername(req.Username); err != nil {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado", nil)
+		return
+	}
+	admin := claims.Subject
+	if req.Description == "" {
+		req.Description = "Recarga de " + admin
+	}
+	txID, err := service.TopUp(req.Username, req.Amount, req.Description)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error registrando la recarga", nil)
+		return
+	}
+	balance, _ := service.Balance(req.Username)
+	sendJSONResponse(w, http.StatusCreated, "success", "Créditos agregados", map[string]interface{}{
+		"tx_id":   txID,
+		"balance": balance,
+	})
+}
+
+// RefundHandler reembolsa el cargo de una petición que falló en el upstream.
+// Solo lo puede llamar el Gateway.
+func RefundHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireInternal(w, r, "Solo el Gateway puede solicitar reembolsos") {
+		return
+	}
+	var req struct {
+		DebitID string `json:"debit_id"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	err := service.Refund(req.DebitID, req.Reason)
+	if errors.Is(err, service.ErrDebitNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Cargo no encontrado", nil)
+		return
+	}
+	if errors.Is(err, service.ErrAlreadyRefunded) {
+		sendJSONResponse(w, http.StatusConflict, "error", "El cargo ya fue reembolsado", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error registrando el reembolso", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Cargo reembolsado", nil)
+}
+
+// RateLimitsHandler devuelve el estado de las ventanas de uso del usuario sin
+// contar una petición
+func RateLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	usage, err := service.GetUsage(claims.Subject, claims.Plan, time.Now())
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo el uso", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Límites de uso", map[string]interface{}{
+		"plan":    claims.Plan,
+		"windows": usage,
+	})
+}
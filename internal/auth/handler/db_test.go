@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+// setupTestDB abre una base de datos vacía en un directorio temporal, la deja
+// como service.DB mientras dura el test y configura la firma de los tokens
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	previous := service.DB
+	if err := service.InitDBAt(filepath.Join(t.TempDir(), "users.db")); err != nil {
+		t.Fatal(err)
+	}
+	db := service.DB
+	// Los "record not found" esperados no ensucian la salida de los tests
+	service.DB = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		service.DB = previous
+	})
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("COOKIE_NAME", "auth_token")
+}
+
+// createUser crea un usuario local activo con el rol indicado
+func createUser(t *testing.T, username, role string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Password: "x", Role: role}
+	if err := service.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// serve ejecuta el handler con un cuerpo JSON y, si se indica, el token en
+// Authorization, y devuelve la respuesta decodificada
+func serve(t *testing.T, h http.HandlerFunc, method, target, token string, body interface{}) (*httptest.ResponseRecorder, Response) {
+	t.Helper()
+	var r *http.Request
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = httptest.NewRequest(method, target, bytes.NewReader(data))
+		r.Header.Set("Content-Type", "application/json")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+// authCookie devuelve el token que la respuesta guardó en la cookie
+func authCookie(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "auth_token" && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+	t.Fatalf("la respuesta no guardó el token: %s", w.Body.String())
+	return ""
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// sendOAuthJSON envía una respuesta en el formato de OAuth 2.0, que no usa
+// el sobre status/message/data del resto de la API
+func sendOAuthJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+func sendOAuthError(w http.ResponseWriter, statusCode int, code, description string) {
+	sendOAuthJSON(w, statusCode, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// verificationURI devuelve la URL de la página de verificación que se muestra al usuario
+func verificationURI() string {
+	if uri := os.Getenv("DEVICE_VERIFICATION_URI"); uri != "" {
+		return uri
+	}
+	return "http://localhost:" + os.Getenv("AUTH_SERVICE_PORT") + "/device"
+}
+
+// DeviceCodeHandler emite un device code y un user code (RFC 8628, sección 3.2)
+func DeviceCodeHandler(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PostFormValue("client_id")
+	if clientID == "" {
+		sendOAuthError(w, http.StatusBadRequest, "invalid_request", "client_id es obligatorio")
+		return
+	}
+	deviceCode, auth, err := service.CreateDeviceAuthorization(clientID)
+	if err != nil {
+		sendOAuthError(w, http.StatusInternalServerError, "server_error", "Error creando la autorización")
+		return
+	}
+	uri := verificationURI()
+	sendOAuthJSON(w, http.StatusOK, map[string]interface{}{
+		"device_code":               deviceCode,
+		"user_code":                 auth.UserCode,
+		"verification_uri":          uri,
+		"verification_uri_complete": uri + "?user_code=" + auth.UserCode,
+		"expires_in":                int(time.Until(auth.ExpiresAt).Seconds()),
+		"interval":                  auth.Interval,
+	})
+}
+
+// DeviceTokenHandler responde a los sondeos del dispositivo (RFC 8628, sección 3.4).
+// No evalúa anomalías: el dispositivo no aporta credenciales propias y la
+// aprobación se hace desde una sesión del navegador que ya pasó esas
+// comprobaciones al iniciarse.
+func DeviceTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("grant_type") != deviceGrantType {
+		sendOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Solo se admite "+deviceGrantType)
+		return
+	}
+	auth, err := service.PollDeviceAuthorization(r.PostFormValue("device_code"), r.PostFormValue("client_id"))
+	switch {
+	case errors.Is(err, service.ErrAuthorizationPending):
+		sendOAuthError(w, http.StatusBadRequest, err.Error(), "El usuario aún no ha aprobado la solicitud")
+		return
+	case errors.Is(err, service.ErrSlowDown):
+		sendOAuthError(w, http.StatusBadRequest, err.Error(), "Sondeo demasiado frecuente, aumente el intervalo")
+		return
+	case errors.Is(err, service.ErrAccessDenied):
+		sendOAuthError(w, http.StatusBadRequest, err.Error(), "El usuario rechazó la solicitud")
+		return
+	case errors.Is(err, service.ErrExpiredToken):
+		sendOAuthError(w, http.StatusBadRequest, err.Error(), "El device code ha expirado")
+		return
+	case errors.Is(err, service.ErrInvalidGrant):
+		sendOAuthError(w, http.StatusBadRequest, err.Error(), "device_code o client_id inválido")
+		return
+	case err != nil:
+		sendOAuthError(w, http.StatusInternalServerError, "server_error", "Error consultando la autorización")
+		return
+	}
+
+	user, err := service.GetUserByUsername(auth.Username)
+	if err != nil || !user.Active() {
+		sendOAuthError(w, http.StatusBadRequest, "access_denied", "El usuario ya no está activo")
+		return
+	}
+	tokenString, err := issueToken(user, tokenBinding{})
+	if err != nil {
+		sendOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	service.RecordAudit(user.Username, service.AuditLogin, "device:"+auth.ClientID, originalClientIP(r))
+	sendOAuthJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": tokenString,
+		"token_type":   "Bearer",
+		"expires_in":   int(service.TokenMaxLifetime().Seconds()),
+	})
+}
+
+var devicePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head><meta charset="utf-8"><title>Autorizar dispositivo</title></head>
+<body>
+<h1>Autorizar dispositivo</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .Username}}
+<p>Sesión iniciada como <strong>{{.Username}}</strong>.</p>
+<form method="POST" action="">
+  <label>Código: <input name="user_code" value="{{.UserCode}}" autocomplete="off"></label>
+  <input type="hidden" name="csrf" value="{{.CSRF}}">
+  <button name="action" value="approve">Aprobar</button>
+  <button name="action" value="deny">Rechazar</button>
+</form>
+{{else}}
+<p>Inicia sesión para continuar.</p>
+<form id="login">
+  <label>Usuario: <input name="username" autocomplete="username" required></label>
+  <label>Contraseña: <input name="password" type="password" autocomplete="current-password" required></label>
+  <button>Iniciar sesión</button>
+</form>
+<form id="verify" hidden>
+  <label>Código de verificación: <input name="code" autocomplete="one-time-code" required></label>
+  <button>Verificar</button>
+</form>
+<p id="error" role="alert"></p>
+<script>
+// El login usa los mismos endpoints JSON que el resto de clientes, así que se
+// aplican las mismas comprobaciones; al guardarse la cookie se recarga la página
+(function () {
+  var login = document.getElementById("login");
+  var verify = document.getElementById("verify");
+  var error = document.getElementById("error");
+  var challengeID = "";
+
+  function post(path, body) {
+    error.textContent = "";
+    return fetch(path, {
+      method: "POST",
+      credentials: "same-origin",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify(body)
+    }).then(function (res) {
+      return res.json().then(function (payload) {
+        if (res.ok) {
+          window.location.reload();
+          return;
+        }
+        if (payload.data && payload.data.error_code === "step_up_required") {
+          challengeID = payload.data.challenge_id;
+          login.hidden = true;
+          verify.hidden = false;
+        }
+        error.textContent = payload.message;
+      });
+    }).catch(function () {
+      error.textContent = "No se pudo contactar con el servidor";
+    });
+  }
+
+  login.addEventListener("submit", function (e) {
+    e.preventDefault();
+    post("/api/v1/login", {username: login.username.value, password: login.password.value});
+  });
+  verify.addEventListener("submit", function (e) {
+    e.preventDefault();
+    post("/api/v1/login/verify", {challenge_id: challengeID, code: verify.code.value});
+  });
+})();
+</script>
+{{end}}
+</body>
+</html>`))
+
+type devicePageData struct {
+	Username string
+	UserCode string
+	CSRF     string
+	Message  string
+}
+
+// csrfToken deriva un token anti-CSRF del token de sesión del usuario
+func csrfToken(session string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("JWT_SECRET")))
+	mac.Write([]byte("device-csrf:" + session))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func renderDevicePage(w http.ResponseWriter, statusCode int, data devicePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(statusCode)
+	devicePage.Execute(w, data)
+}
+
+// DeviceVerificationPageHandler muestra la página donde el usuario introduce el código
+func DeviceVerificationPageHandler(w http.ResponseWriter, r *http.Request) {
+	data := devicePageData{UserCode: r.URL.Query().Get("user_code")}
+	if claims, err := parseTokenFromRequest(r); err == nil {
+		session, _ := tokenFromRequest(r)
+		data.Username = claims.Subject
+		data.CSRF = csrfToken(session)
+	}
+	renderDevicePage(w, http.StatusOK, data)
+}
+
+// DeviceVerificationHandler aprueba o rechaza la solicitud del dispositivo
+func DeviceVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		renderDevicePage(w, http.StatusUnauthorized, devicePageData{Message: "Sesión no válida: " + err.Error()})
+		return
+	}
+	session, _ := tokenFromRequest(r)
+	username := claims.Subject
+	data := devicePageData{Username: username, CSRF: csrfToken(session)}
+
+	// Aprobar un dispositivo emite credenciales nuevas para el usuario
+	if claims.ActorSubject() != "" {
+		data.Message = "No se pueden autorizar dispositivos con un token de suplantación"
+		renderDevicePage(w, http.StatusForbidden, data)
+		return
+	}
+
+	if !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(data.CSRF)) {
+		data.Message = "Formulario inválido, recargue la página"
+		renderDevicePage(w, http.StatusForbidden, data)
+		return
+	}
+
+	approve := r.PostFormValue("action") == "approve"
+	err = service.ResolveDeviceAuthorization(r.PostFormValue("user_code"), username, approve)
+	if err != nil {
+		data.UserCode = r.PostFormValue("user_code")
+		data.Message = "Código inválido o expirado"
+		renderDevicePage(w, http.StatusBadRequest, data)
+		return
+	}
+	if approve {
+		service.RecordAudit(username, service.AuditDeviceApproved, service.NormalizeUserCode(r.PostFormValue("user_code")), originalClientIP(r))
+		data.Message = "Dispositivo autorizado. Puede volver a su terminal."
+	} else {
+		data.Message = "Solicitud rechazada."
+	}
+	renderDevicePage(w, http.StatusOK, data)
+}
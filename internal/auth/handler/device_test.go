@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+// postForm ejecuta el handler con un formulario y, si se indica, la sesión en la cookie
+func postForm(h http.HandlerFunc, target, session string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if session != "" {
+		r.AddCookie(&http.Cookie{Name: "auth_token", Value: session})
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func oauthError(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	code, _ := body["error"].(string)
+	return code
+}
+
+func TestDeviceGrant(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("TOKEN_MAX_LIFETIME_HOURS", "2")
+	user := createUser(t, "rick", models.RoleUser)
+
+	w := postForm(DeviceCodeHandler, "/api/v1/device/code", "", url.Values{"client_id": {"cli"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("device/code: %d %s", w.Code, w.Body.String())
+	}
+	var codes struct {
+		DeviceCode string `json:"device_code"`
+		UserCode   string `json:"user_code"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &codes)
+	poll := url.Values{"grant_type": {deviceGrantType}, "device_code": {codes.DeviceCode}, "client_id": {"cli"}}
+	resetPoll := func() {
+		service.DB.Model(&models.DeviceAuthorization{}).Where("user_code = ?", codes.UserCode).Update("last_polled_at", nil)
+	}
+
+	w = postForm(DeviceTokenHandler, "/api/v1/device/token", "", poll)
+	if w.Code != http.StatusBadRequest || oauthError(t, w) != "authorization_pending" {
+		t.Fatalf("sin aprobar: %d %s", w.Code, w.Body.String())
+	}
+	w = postForm(DeviceTokenHandler, "/api/v1/device/token", "", poll)
+	if oauthError(t, w) != "slow_down" {
+		t.Fatalf("sondeo inmediato: %s", w.Body.String())
+	}
+
+	// Aprobación desde el navegador con sesión iniciada
+	session, err := issueToken(user, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = postForm(DeviceVerificationHandler, "/device", session, url.Values{"user_code": {codes.UserCode}, "action": {"approve"}})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("aprobación sin csrf: %d", w.Code)
+	}
+	approve := url.Values{"user_code": {codes.UserCode}, "action": {"approve"}, "csrf": {csrfToken(session)}}
+	if w = postForm(DeviceVerificationHandler, "/device", session, approve); w.Code != http.StatusOK {
+		t.Fatalf("aprobación: %d %s", w.Code, w.Body.String())
+	}
+
+	resetPoll()
+	w = postForm(DeviceTokenHandler, "/api/v1/device/token", "", poll)
+	if w.Code != http.StatusOK {
+		t.Fatalf("aprobada: %d %s", w.Code, w.Body.String())
+	}
+	var issued struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &issued)
+	if !service.TokenExists(issued.AccessToken) {
+		t.Fatal("el token emitido no quedó registrado")
+	}
+	if issued.ExpiresIn != 2*3600 {
+		t.Fatalf("expires_in = %d, se esperaba el límite configurado", issued.ExpiresIn)
+	}
+
+	resetPoll()
+	w = postForm(DeviceTokenHandler, "/api/v1/device/token", "", poll)
+	if oauthError(t, w) != "invalid_grant" {
+		t.Fatalf("segundo canje: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeviceGrantDeniedAndExpired(t *testing.T) {
+	setupTestDB(t)
+	user := createUser(t, "rick", models.RoleUser)
+	session, err := issueToken(user, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deviceCode, auth, err := service.CreateDeviceAuthorization("cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deny := url.Values{"user_code": {auth.UserCode}, "action": {"deny"}, "csrf": {csrfToken(session)}}
+	if w := postForm(DeviceVerificationHandler, "/device", session, deny); w.Code != http.StatusOK {
+		t.Fatalf("rechazo: %d", w.Code)
+	}
+	poll := url.Values{"grant_type": {deviceGrantType}, "device_code": {deviceCode}, "client_id": {"cli"}}
+	if w := postForm(DeviceTokenHandler, "/api/v1/device/token", "", poll); oauthError(t, w) != "access_denied" {
+		t.Fatalf("rechazada: %s", w.Body.String())
+	}
+
+	deviceCode, auth, err = service.CreateDeviceAuthorization("cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.DB.Model(auth).Update("expires_at", time.Now().Add(-time.Second))
+	poll.Set("device_code", deviceCode)
+	if w := postForm(DeviceTokenHandler, "/api/v1/device/token", "", poll); oauthError(t, w) != "expired_token" {
+		t.Fatalf("expirada: %s", w.Body.String())
+	}
+}
+
+func TestDeviceVerificationPageOffersLogin(t *testing.T) {
+	setupTestDB(t)
+	user := createUser(t, "rick", models.RoleUser)
+
+	r := httptest.NewRequest("GET", "/device?user_code=BCDF-GHJK", nil)
+	w := httptest.NewRecorder()
+	DeviceVerificationPageHandler(w, r)
+	body := w.Body.String()
+	if !strings.Contains(body, `id="login"`) || !strings.Contains(body, `"/api/v1/login"`) {
+		t.Fatalf("la página sin sesión no ofrece el login:\n%s", body)
+	}
+
+	session, err := issueToken(user, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = httptest.NewRequest("GET", "/device?user_code=BCDF-GHJK", nil)
+	r.AddCookie(&http.Cookie{Name: "auth_token", Value: session})
+	w = httptest.NewRecorder()
+	DeviceVerificationPageHandler(w, r)
+	body = w.Body.String()
+	if strings.Contains(body, `id="login"`) || !strings.Contains(body, csrfToken(session)) || !strings.Contains(body, "BCDF-GHJK") {
+		t.Fatalf("la página con sesión no muestra la aprobación:\n%s", body)
+	}
+}
@@ -2,16 +2,30 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/authenticator"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
 	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var tokenStore = make(map[string]int)
+// authBackend verifica las credenciales en LoginHandler
+var authBackend authenticator.Authenticator = authenticator.Local{}
+
+// SetAuthenticator configura los backends usados para el login
+func SetAuthenticator(a authenticator.Authenticator) {
+	authBackend = a
+}
 
 type Response struct {
 	Status  string      `json:"status"`
@@ -29,135 +43,336 @@ func sendJSONResponse(w http.ResponseWriter, statusCode int, status, message str
 	})
 }
 
+// setAuthCookie guarda el token en la cookie del cliente
+func setAuthCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     os.Getenv("COOKIE_NAME"),
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+	})
+}
+
+// clearAuthCookie elimina la cookie del token en el cliente
+func clearAuthCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     os.Getenv("COOKIE_NAME"),
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   -1, // Eliminar la cookie
+	})
+}
+
+// clientIP devuelve la IP remota de la petición sin el puerto
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// issueToken firma un JWT para el usuario y lo registra para poder revocarlo.
+// Los mensajes de error se pueden devolver tal cual al cliente.
+func issueToken(user *models.User, binding tokenBinding) (string, error) {
+	return issueTokenWithClaims(user, time.Now().Add(service.TokenMaxLifetime()), nil, binding)
+}
+
+// issueTokenWithClaims permite indicar la expiración absoluta del token, que
+// al renovar es la del token anterior, y, para los tokens de suplantación, el
+// actor
+func issueTokenWithClaims(user *models.User, expiresAt time.Time, actor *tokens.Actor, binding tokenBinding) (string, error) {
+	config := tokens.ConfigFromEnv()
+	claims, err := config.NewClaimsUntil(user.Username, expiresAt)
+	if err != nil {
+		return "", errors.New("Error generating token")
+	}
+	claims.Role = user.Role
+	claims.Plan = user.Plan
+	claims.Actor = actor
+	claims.Scope = tokenScope(user.Role)
+
+	// Los usuarios sin movimientos en el libro reciben los créditos iniciales de su plan
+	if err := service.EnsureSignupGrant(user); err != nil {
+		return "", errors.New("Error otorgando los créditos iniciales")
+	}
+
+	// La organización viaja en el token para que el Gateway pueda contabilizar su consumo
+	if membership, err := service.GetMembership(user.Username); err == nil {
+		claims.Org = membership.OrgID
+	}
+	tokenString, err := config.Sign(claims)
+	if err != nil {
+		if len(config.Secret) == 0 {
+			return "", err
+		}
+		return "", errors.New("Error generating token")
+	}
+	if err := service.StoreToken(tokenString, user.Username, claims.ExpiresAt.Time, binding.Modes, binding.Fingerprint); err != nil {
+		return "", errors.New("Error guardando el token")
+	}
+	return tokenString, nil
+}
+
+// tokenScope devuelve los scopes de un token según el rol. Todos los tokens
+// reciben TOKEN_SCOPES (por defecto "api:read") y los administradores además "admin".
+func tokenScope(role string) string {
+	scope := os.Getenv("TOKEN_SCOPES")
+	if scope == "" {
+		scope = "api:read"
+	}
+	if role == models.RoleAdmin {
+		scope += " admin"
+	}
+	return scope
+}
+
+// tokenFromRequest obtiene el token de la cookie o, para clientes sin
+// cookies como las herramientas de terminal, del header Authorization: Bearer
+func tokenFromRequest(r *http.Request) (string, bool) {
+	if cookie, err := r.Cookie(os.Getenv("COOKIE_NAME")); err == nil && cookie.Value != "" {
+		return cookie.Value, true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		return token, true
+	}
+	return "", false
+}
+
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	mode := registrationMode()
+	if mode == RegistrationClosed {
+		sendJSONResponse(w, http.StatusForbidden, "error", "El registro está cerrado", nil)
+		return
+	}
 	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username   string `json:"username"`
+		Password   string `json:"password"`
+		InviteCode string `json:"invite_code"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
 		return
 	}
+	// El prefijo sa: está reservado para las cuentas de servicio
+	if req.Username == "" || strings.HasPrefix(req.Username, models.ServiceAccountPrefix) {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Nombre de usuario inválido", nil)
+		return
+	}
+	if mode == RegistrationInvite && req.InviteCode == "" {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Se requiere un código de invitación", nil)
+		return
+	}
+	if !checkPasswordNotBreached(w, req.Password) {
+		return
+	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error en hash", nil)
 		return
 	}
+	if req.InviteCode != "" {
+		_, invite, err := service.CreateUserWithInvite(req.Username, string(hash), req.InviteCode)
+		if errors.Is(err, service.ErrInviteInvalid) {
+			sendJSONResponse(w, http.StatusForbidden, "error", "Código de invitación inválido, expirado o agotado", nil)
+			return
+		}
+		if err != nil {
+			sendJSONResponse(w, http.StatusConflict, "error", "Usuario ya existe", nil)
+			return
+		}
+		// Se registra el id y no el código, que puede tener usos pendientes
+		service.RecordAudit(req.Username, service.AuditRegister, "invite:"+strconv.Itoa(invite.ID), originalClientIP(r))
+		sendJSONResponse(w, http.StatusCreated, "success", "Usuario registrado exitosamente", nil)
+		return
+	}
 	if err := service.CreateUser(req.Username, string(hash)); err != nil {
 		sendJSONResponse(w, http.StatusConflict, "error", "Usuario ya existe", nil)
 		return
 	}
+	service.RecordAudit(req.Username, service.AuditRegister, "", originalClientIP(r))
 	sendJSONResponse(w, http.StatusCreated, "success", "Usuario registrado exitosamente", nil)
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		KeyThumbprint string `json:"key_thumbprint"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
 		return
 	}
-	user, err := service.GetUserByUsername(req.Username)
-	if err != nil {
+	identity, err := authBackend.Authenticate(req.Username, req.Password)
+	if errors.Is(err, authenticator.ErrInvalidCredentials) {
+		service.RecordAudit(req.Username, service.AuditLoginFailed, "", originalClientIP(r))
 		sendJSONResponse(w, http.StatusUnauthorized, "error", "Usuario o contraseña incorrectos", nil)
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		sendJSONResponse(w, http.StatusUnauthorized, "error", "Usuario o contraseña incorrectos", nil)
+	if err != nil {
+		sendJSONResponse(w, http.StatusServiceUnavailable, "error", "Servicio de autenticación no disponible", nil)
 		return
 	}
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		sendJSONResponse(w, http.StatusInternalServerError, "error", "JWT secret not set", nil)
+	// Aprovisionar el usuario local en su primer login externo
+	user, err := service.ProvisionUser(identity.Username, identity.Source)
+	if errors.Is(err, service.ErrSourceMismatch) {
+		service.RecordAudit(req.Username, service.AuditLoginFailed, "source:"+identity.Source, originalClientIP(r))
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Usuario o contraseña incorrectos", nil)
 		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
-		"username": user.Username,
-	})
-	tokenString, err := token.SignedString([]byte(secret))
 	if err != nil {
-		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error generating token", nil)
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo el usuario", nil)
 		return
 	}
-	tokenStore[tokenString] = 5
-	http.SetCookie(w, &http.Cookie{
-		Name:     os.Getenv("COOKIE_NAME"),
-		Value:    tokenString,
-		Path:     "/",
-		HttpOnly: true,
-	})
-	sendJSONResponse(w, http.StatusOK, "success", "Login exitoso", map[string]interface{}{
-		"username": user.Username,
-		"message":  "Token guardado en cookie",
-	})
+	if !user.Active() {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Cuenta desactivada", nil)
+		return
+	}
+	signal := loginSignal(r, user.Username, req.KeyThumbprint)
+	if !checkLoginAnomalies(w, r, signal) {
+		return
+	}
+	completeLogin(w, r, user, signal, req.KeyThumbprint, "source:"+identity.Source)
 }
 
 func ValidateTokenHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie(os.Getenv("COOKIE_NAME"))
-	if err != nil {
+	// Los errores internos no cambian outcome; el resto de salidas lo fijan
+	outcome := validationError
+	defer func() { tokenValidations.Inc(outcome) }()
+
+	tokenString, ok := tokenFromRequest(r)
+	if !ok {
+		outcome = validationMissing
 		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token no encontrado en cookie", nil)
 		return
 	}
 
-	tokenString := cookie.Value
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
+	config := tokens.ConfigFromEnv()
+	if len(config.Secret) == 0 {
 		sendJSONResponse(w, http.StatusInternalServerError, "error", "JWT secret not set", nil)
 		return
 	}
 
-	claims := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	claims, err := config.Parse(tokenString)
 	if err != nil {
+		outcome = parseOutcome(err)
 		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token inválido", nil)
 		return
 	}
+	username := claims.Subject
+	middleware.SetUser(r.Context(), username)
 
-	usos, ok := tokenStore[tokenString]
-	if !ok {
+	// Un token ligado solo es válido desde el cliente que lo obtuvo
+	modes, fingerprint, err := service.TokenBinding(tokenString)
+	if errors.Is(err, service.ErrTokenNotFound) {
+		outcome = validationExpired
 		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado", nil)
 		return
 	}
-
-	if usos <= 0 {
-		// Eliminar el token del store
-		delete(tokenStore, tokenString)
-		// Eliminar la cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     os.Getenv("COOKIE_NAME"),
-			Value:    "",
-			Path:     "/",
-			HttpOnly: true,
-			MaxAge:   -1, // Eliminar la cookie
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error validando el token", nil)
+		return
+	}
+	if fingerprint != "" && requestBinding(r, modes).Fingerprint != fingerprint {
+		detail := "modes:" + modes + " ua:" + r.UserAgent()
+		service.RecordAudit(username, service.AuditTokenBindingMismatch, detail, originalClientIP(r))
+		outcome = validationInvalid
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token usado desde un cliente distinto al que lo obtuvo", map[string]interface{}{
+			"error_code": "token_binding_mismatch",
 		})
-		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado por uso máximo alcanzado", nil)
 		return
 	}
 
-	// Reducir el contador de usos
-	tokenStore[tokenString] = usos - 1
+	// Los tokens de organización consumen la cuota compartida de la organización
+	orgID := claims.Org
+	if orgID != 0 {
+		membership, err := service.GetMembership(username)
+		if err != nil || membership.OrgID != orgID {
+			outcome = validationInvalid
+			sendJSONResponse(w, http.StatusUnauthorized, "error", "El usuario ya no pertenece a la organización del token", nil)
+			return
+		}
+	}
 
-	// Si es el último uso, eliminar el token
-	if usos-1 == 0 {
-		delete(tokenStore, tokenString)
-		// Eliminar la cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     os.Getenv("COOKIE_NAME"),
-			Value:    "",
-			Path:     "/",
-			HttpOnly: true,
-			MaxAge:   -1, // Eliminar la cookie
+	// Cada validación desliza la ventana de inactividad hasta el límite absoluto
+	expiresAt, err := service.ExtendTokenIdle(tokenString)
+	if errors.Is(err, service.ErrTokenNotFound) {
+		outcome = validationExpired
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado", nil)
+		return
+	}
+	if errors.Is(err, service.ErrTokenIdle) {
+		outcome = validationExpired
+		clearAuthCookie(w)
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado por inactividad", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error validando el token", nil)
+		return
+	}
+
+	// La medición es independiente de la autenticación: cada usuario tiene
+	// ventanas de uso por minuto, hora y día según su plan. Ventanas, cuota de
+	// la organización y cargo se cobran juntos: si algo rechaza la petición no
+	// se cobra nada. El Gateway reembolsa el cargo si el upstream falla.
+	description := strings.TrimSpace(proxyHeader(r, "X-Original-Method") + " " + proxyHeader(r, "X-Original-URI"))
+	charge, err := service.ChargeRequest(username, claims.Plan, orgID, description, time.Now())
+	if usage := charge.Usage; usage != nil {
+		for header, value := range usage.RateLimitHeaders() {
+			w.Header().Set(header, value)
+		}
+	}
+	if errors.Is(err, service.ErrRateLimited) {
+		outcome = validationExhausted
+		retryAfter := int(math.Ceil(charge.Usage.RetryAfter(time.Now()).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		sendJSONResponse(w, http.StatusTooManyRequests, "error", "Límite de peticiones alcanzado", map[string]interface{}{
+			"rate_limit":  charge.Usage.Windows,
+			"retry_after": retryAfter,
 		})
+		return
+	}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		outcome = validationExhausted
+		sendJSONResponse(w, http.StatusTooManyRequests, "error", "Cuota de la organización agotada", nil)
+		return
+	}
+	if errors.Is(err, service.ErrInsufficientCredits) {
+		outcome = validationExhausted
+		sendJSONResponse(w, http.StatusPaymentRequired, "error", "Créditos insuficientes", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error registrando el uso", nil)
+		return
 	}
 
-	sendJSONResponse(w, http.StatusOK, "success", "Token válido", map[string]interface{}{
-		"usos_restantes": usos - 1,
-		"username":       claims["username"],
-		"message":        "Token expirará después de este uso",
-	})
+	data := map[string]interface{}{
+		"username":   username,
+		"scopes":     claims.Scopes(),
+		"rate_limit": charge.Usage.Windows,
+		// expires_at permite al Gateway renovar la expiración de la cookie
+		"expires_at":          expiresAt.UTC().Format(time.RFC3339),
+		"absolute_expires_at": claims.ExpiresAt.UTC().Format(time.RFC3339),
+		"debit_id":            charge.DebitID,
+		"credits_remaining":   charge.Credits,
+	}
+	if orgID != 0 {
+		data["org_id"] = orgID
+		data["org_quota_remaining"] = charge.OrgQuotaRemaining
+	}
+
+	// Cada petición hecha con un token de suplantación queda auditada con ambas identidades
+	if actor := claims.ActorSubject(); actor != "" {
+		// Método, ruta e IP solo se aceptan del Gateway para que no se puedan falsificar
+		detail := "actor:" + actor + " " + proxyHeader(r, "X-Original-Method") + " " + proxyHeader(r, "X-Original-URI")
+		service.RecordAudit(username, service.AuditImpersonatedRequest, strings.TrimSpace(detail), originalClientIP(r))
+		data["actor"] = actor
+	}
+
+	outcome = validationValid
+	sendJSONResponse(w, http.StatusOK, "success", "Token válido", data)
 }
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+const testPassword = "wubba-lubba-dub-dub-42"
+
+func register(t *testing.T, username, inviteCode string) int {
+	t.Helper()
+	w, _ := serve(t, RegisterHandler, "POST", "/api/v1/register", "", map[string]string{
+		"username": username, "password": testPassword, "invite_code": inviteCode,
+	})
+	return w.Code
+}
+
+func TestRegisterModes(t *testing.T) {
+	tests := []struct {
+		mode       string
+		withInvite bool
+		want       int
+	}{
+		{mode: "", want: http.StatusCreated},
+		{mode: RegistrationOpen, want: http.StatusCreated},
+		{mode: RegistrationOpen, withInvite: true, want: http.StatusCreated},
+		{mode: RegistrationInvite, want: http.StatusForbidden},
+		{mode: RegistrationInvite, withInvite: true, want: http.StatusCreated},
+		{mode: RegistrationClosed, want: http.StatusForbidden},
+		{mode: RegistrationClosed, withInvite: true, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/invite="+strconv.FormatBool(tt.withInvite), func(t *testing.T) {
+			setupTestDB(t)
+			t.Setenv("REGISTRATION_MODE", tt.mode)
+			code := ""
+			if tt.withInvite {
+				invite, err := service.CreateInvite("admin", 1, time.Hour, models.RoleUser, "free")
+				if err != nil {
+					t.Fatal(err)
+				}
+				code = invite.Code
+			}
+			if got := register(t, "rick", code); got != tt.want {
+				t.Fatalf("registro = %d, se esperaba %d", got, tt.want)
+			}
+			_, err := service.GetUserByUsername("rick")
+			if created := err == nil; created != (tt.want == http.StatusCreated) {
+				t.Fatalf("usuario creado = %v con respuesta %d", created, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterWithInvite(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("REGISTRATION_MODE", RegistrationInvite)
+	invite, err := service.CreateInvite("admin", 2, time.Hour, models.RoleAdmin, "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := register(t, "rick", invite.Code); got != http.StatusCreated {
+		t.Fatalf("primer uso = %d", got)
+	}
+	user, err := service.GetUserByUsername("rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleAdmin || user.Plan != "pro" {
+		t.Fatalf("rol y plan = %q, %q; se esperaban los de la invitación", user.Role, user.Plan)
+	}
+	// La auditoría identifica la invitación sin guardar el código
+	var event models.AuditEvent
+	if err := service.DB.Where("username = ? AND action = ?", "rick", service.AuditRegister).First(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	if event.Detail != "invite:"+strconv.Itoa(invite.ID) {
+		t.Fatalf("detalle de auditoría = %q", event.Detail)
+	}
+
+	// Un nombre ya usado no consume la invitación
+	if got := register(t, "rick", invite.Code); got != http.StatusConflict {
+		t.Fatalf("usuario repetido = %d", got)
+	}
+	if got := register(t, "morty", invite.Code); got != http.StatusCreated {
+		t.Fatalf("segundo uso = %d", got)
+	}
+	if got := register(t, "summer", invite.Code); got != http.StatusForbidden {
+		t.Fatalf("invitación agotada = %d", got)
+	}
+	if got := register(t, "beth", "NO-EXISTE"); got != http.StatusForbidden {
+		t.Fatalf("código inexistente = %d", got)
+	}
+}
+
+func TestRegisterWithExpiredInvite(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("REGISTRATION_MODE", RegistrationInvite)
+	invite, err := service.CreateInvite("admin", 5, time.Hour, models.RoleUser, "free")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DB.Model(invite).Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := register(t, "rick", invite.Code); got != http.StatusForbidden {
+		t.Fatalf("invitación expirada = %d", got)
+	}
+	if _, err := service.GetUserByUsername("rick"); err == nil {
+		t.Fatal("se creó el usuario con una invitación expirada")
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+)
+
+const (
+	defaultImpersonationTTL = 15 * time.Minute
+	maxImpersonationTTL     = time.Hour
+)
+
+// rejectImpersonation impide que un token de suplantación realice operaciones
+// sobre las credenciales del usuario. Devuelve true si la petición se rechazó.
+func rejectImpersonation(w http.ResponseWriter, claims *tokens.Claims) bool {
+	if claims.ActorSubject() == "" {
+		return false
+	}
+	sendJSONResponse(w, http.StatusForbidden, "error", "Operación no permitida con un token de suplantación", nil)
+	return true
+}
+
+// ImpersonateHandler emite un token de corta duración para actuar como otro
+// usuario (solo administradores). El token se devuelve en el cuerpo y no en
+// la cookie para no reemplazar la sesión del administrador.
+func ImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok || rejectImpersonation(w, claims) {
+		return
+	}
+	var req struct {
+		Username   string `json:"username"`
+		Reason     string `json:"reason"`
+		TTLMinutes int    `json:"ttl_minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	if req.Reason == "" {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Se requiere un motivo para la suplantación", nil)
+		return
+	}
+	ttl := time.Duration(req.TTLMinutes) * time.Minute
+	if ttl < 0 || ttl > maxImpersonationTTL {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "ttl_minutes debe estar entre 1 y 60", nil)
+		return
+	}
+	if ttl == 0 {
+		ttl = defaultImpersonationTTL
+	}
+
+	user, err := service.GetUserByUsername(req.Username)
+	if err != nil || !user.Active() {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado", nil)
+		return
+	}
+	// No se permite suplantar a otros administradores
+	if user.Role == models.RoleAdmin {
+		sendJSONResponse(w, http.StatusForbidden, "error", "No se puede suplantar a un administrador", nil)
+		return
+	}
+
+	admin := claims.Subject
+	tokenString, err := issueTokenWithClaims(user, time.Now().Add(ttl), &tokens.Actor{Subject: admin}, tokenBinding{})
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", err.Error(), nil)
+		return
+	}
+	service.RecordAudit(user.Username, service.AuditImpersonate, "actor:"+admin+" reason:"+req.Reason, originalClientIP(r))
+	sendJSONResponse(w, http.StatusCreated, "success", "Token de suplantación emitido", map[string]interface{}{
+		"token":      tokenString,
+		"username":   user.Username,
+		"actor":      admin,
+		"expires_in": int(ttl.Seconds()),
+	})
+}
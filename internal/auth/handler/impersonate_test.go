@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+)
+
+// impersonate pide un token de suplantación con la sesión indicada
+func impersonate(t *testing.T, session string, body map[string]interface{}) (int, Response) {
+	t.Helper()
+	w, resp := serve(t, ImpersonateHandler, "POST", "/api/v1/admin/impersonate", session, body)
+	return w.Code, resp
+}
+
+func TestImpersonateRejected(t *testing.T) {
+	setupTestDB(t)
+	admin := createUser(t, "root", models.RoleAdmin)
+	user := createUser(t, "rick", models.RoleUser)
+	createUser(t, "otro-admin", models.RoleAdmin)
+	adminSession, err := issueToken(admin, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userSession, err := issueToken(user, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		body    map[string]interface{}
+		want    int
+	}{
+		{name: "sin ser administrador", session: userSession,
+			body: map[string]interface{}{"username": "rick", "reason": "ticket"}, want: http.StatusForbidden},
+		{name: "sin motivo", session: adminSession,
+			body: map[string]interface{}{"username": "rick"}, want: http.StatusBadRequest},
+		{name: "a un administrador", session: adminSession,
+			body: map[string]interface{}{"username": "otro-admin", "reason": "ticket"}, want: http.StatusForbidden},
+		{name: "duración mayor de una hora", session: adminSession,
+			body: map[string]interface{}{"username": "rick", "reason": "ticket", "ttl_minutes": 61}, want: http.StatusBadRequest},
+		{name: "duración negativa", session: adminSession,
+			body: map[string]interface{}{"username": "rick", "reason": "ticket", "ttl_minutes": -5}, want: http.StatusBadRequest},
+		{name: "usuario inexistente", session: adminSession,
+			body: map[string]interface{}{"username": "jerry", "reason": "ticket"}, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, resp := impersonate(t, tt.session, tt.body); code != tt.want {
+				t.Fatalf("respuesta = %d (%s), se esperaba %d", code, resp.Message, tt.want)
+			}
+		})
+	}
+	var count int64
+	service.DB.Model(&models.AuditEvent{}).Where("action = ?", service.AuditImpersonate).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d suplantaciones rechazadas quedaron auditadas como emitidas", count)
+	}
+}
+
+func TestImpersonateToken(t *testing.T) {
+	setupTestDB(t)
+	admin := createUser(t, "root", models.RoleAdmin)
+	createUser(t, "rick", models.RoleUser)
+	adminSession, err := issueToken(admin, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := impersonate(t, adminSession, map[string]interface{}{"username": "rick", "reason": "ticket 1234", "ttl_minutes": 30})
+	if code != http.StatusCreated {
+		t.Fatalf("suplantación = %d (%s)", code, resp.Message)
+	}
+	data := resp.Data.(map[string]interface{})
+	token := data["token"].(string)
+	claims, err := tokens.ConfigFromEnv().Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "rick" || claims.ActorSubject() != "root" {
+		t.Fatalf("sub = %q, act = %q", claims.Subject, claims.ActorSubject())
+	}
+	if remaining := time.Until(claims.ExpiresAt.Time); remaining > 30*time.Minute || remaining < 29*time.Minute {
+		t.Fatalf("el token caduca en %v, se esperaban 30m", remaining)
+	}
+	var event models.AuditEvent
+	if err := service.DB.Where("action = ?", service.AuditImpersonate).First(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	if event.Username != "rick" || event.Detail != "actor:root reason:ticket 1234" {
+		t.Fatalf("auditoría = %q, %q", event.Username, event.Detail)
+	}
+
+	// Las peticiones con el token quedan auditadas con ambas identidades
+	w, resp := serve(t, ValidateTokenHandler, "GET", "/api/v1/validate", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("validación = %d (%s)", w.Code, resp.Message)
+	}
+	if actor := resp.Data.(map[string]interface{})["actor"]; actor != "root" {
+		t.Fatalf("actor en la validación = %v", actor)
+	}
+	var request models.AuditEvent
+	if err := service.DB.Where("action = ?", service.AuditImpersonatedRequest).First(&request).Error; err != nil {
+		t.Fatal(err)
+	}
+	if request.Username != "rick" || request.Detail != "actor:root" {
+		t.Fatalf("auditoría de la petición = %q, %q", request.Username, request.Detail)
+	}
+}
+
+func TestImpersonationTokenCannotManageAccount(t *testing.T) {
+	setupTestDB(t)
+	createUser(t, "root", models.RoleAdmin)
+	user := createUser(t, "rick", models.RoleUser)
+	token, err := issueTokenWithClaims(user, time.Now().Add(15*time.Minute), &tokens.Actor{Subject: "root"}, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		body    interface{}
+	}{
+		{name: "renovar", handler: RefreshHandler, target: "/api/v1/refresh"},
+		{name: "cambiar la contraseña", handler: ChangePasswordHandler, target: "/api/v1/me/password",
+			body: map[string]string{"current_password": "x", "new_password": testPassword}},
+		{name: "desactivar la cuenta", handler: DeactivateSelfHandler, target: "/api/v1/me/deactivate"},
+		{name: "suplantar a otro usuario", handler: ImpersonateHandler, target: "/api/v1/admin/impersonate",
+			body: map[string]string{"username": "rick", "reason": "ticket"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w, resp := serve(t, tt.handler, "POST", tt.target, token, tt.body); w.Code != http.StatusForbidden {
+				t.Fatalf("respuesta = %d (%s), se esperaba 403", w.Code, resp.Message)
+			}
+		})
+	}
+	if stored, _ := service.GetUserByUsername("rick"); stored.DeactivatedAt != nil || stored.Password != "x" {
+		t.Fatal("el token de suplantación modificó la cuenta")
+	}
+	if !service.TokenExists(token) {
+		t.Fatal("el intento de renovación revocó el token")
+	}
+}
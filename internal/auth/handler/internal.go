@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// internalSecret es el secreto compartido con el Gateway para los endpoints
+// /internal. Sin configurar, esos endpoints rechazan todas las peticiones.
+var internalSecret string
+
+// SetInternalSecret configura el secreto de los endpoints internos
+func SetInternalSecret(secret string) {
+	internalSecret = secret
+}
+
+// requireInternal verifica que la petición lleve el secreto compartido en
+// X-Internal-Auth. Si no, escribe el error y devuelve false.
+func requireInternal(w http.ResponseWriter, r *http.Request, message string) bool {
+	provided := r.Header.Get("X-Internal-Auth")
+	if internalSecret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(internalSecret)) != 1 {
+		sendJSONResponse(w, http.StatusForbidden, "error", message, nil)
+		return false
+	}
+	return true
+}
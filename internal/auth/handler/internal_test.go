@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+	"github.com/yourusername/api_ricky_and_morty/internal/usage"
+)
+
+func TestRequireInternal(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		provided string
+		want     bool
+	}{
+		{"secreto correcto", "s3cret", "s3cret", true},
+		{"secreto incorrecto", "s3cret", "gateway-service", false},
+		{"sin cabecera", "s3cret", "", false},
+		{"sin secreto configurado", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetInternalSecret(tt.secret)
+			t.Cleanup(func() { SetInternalSecret("") })
+			r := httptest.NewRequest("POST", "/api/v1/internal/refund", nil)
+			if tt.provided != "" {
+				r.Header.Set("X-Internal-Auth", tt.provided)
+			}
+			w := httptest.NewRecorder()
+			if got := requireInternal(w, r, "Solo el Gateway"); got != tt.want {
+				t.Fatalf("requireInternal = %v, se esperaba %v", got, tt.want)
+			}
+			if !tt.want && w.Code != http.StatusForbidden {
+				t.Fatalf("estado = %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestRecordBatchUsageInvalidCount(t *testing.T) {
+	for _, count := range []int{0, -3, usage.MaxBatchCount + 1} {
+		result := recordBatchUsage(tokens.Config{}, usageBatchEntry{Token: "x", Count: count})
+		if result.Rejected != "invalid_count" || result.Accepted != 0 {
+			t.Errorf("count %d: %+v", count, result)
+		}
+	}
+}
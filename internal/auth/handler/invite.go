@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"gorm.io/gorm"
+)
+
+const (
+	RegistrationOpen   = "open"
+	RegistrationInvite = "invite-only"
+	RegistrationClosed = "closed"
+)
+
+// registrationMode devuelve el modo de registro configurado en REGISTRATION_MODE
+func registrationMode() string {
+	switch mode := strings.ToLower(os.Getenv("REGISTRATION_MODE")); mode {
+	case RegistrationInvite, RegistrationClosed:
+		return mode
+	default:
+		return RegistrationOpen
+	}
+}
+
+// CreateInviteHandler crea un código de invitación (solo administradores)
+func CreateInviteHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		MaxUses      int    `json:"max_uses"`
+		ExpiresInHrs int    `json:"expires_in_hours"`
+		Role         string `json:"role"`
+		Plan         string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	if req.MaxUses <= 0 {
+		req.MaxUses = 1
+	}
+	if req.ExpiresInHrs <= 0 {
+		req.ExpiresInHrs = 72
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if req.Role != models.RoleUser && req.Role != models.RoleAdmin {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Rol inválido", nil)
+		return
+	}
+	if req.Plan == "" {
+		req.Plan = "free"
+	}
+	if !service.ValidPlan(req.Plan) {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Plan inválido", nil)
+		return
+	}
+
+	createdBy := claims.Subject
+	invite, err := service.CreateInvite(createdBy, req.MaxUses, time.Duration(req.ExpiresInHrs)*time.Hour, req.Role, req.Plan)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error creando la invitación", nil)
+		return
+	}
+	service.RecordAudit(createdBy, service.AuditInviteCreated, invite.Code, originalClientIP(r))
+	sendJSONResponse(w, http.StatusCreated, "success", "Invitación creada", invite)
+}
+
+// ListInvitesHandler lista los códigos de invitación (solo administradores)
+func ListInvitesHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	invites, err := service.ListInvites()
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo invitaciones", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Invitaciones obtenidas", invites)
+}
+
+// RevokeInviteHandler revoca un código de invitación (solo administradores)
+func RevokeInviteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	err := service.RevokeInvite(mux.Vars(r)["code"])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Invitación no encontrada", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error revocando la invitación", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Invitación revocada", nil)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/metrics"
+)
+
+// Resultados de /validate para auth_token_validations_total
+const (
+	validationValid     = "valid"
+	validationMissing   = "missing"
+	validationInvalid   = "invalid"
+	validationExpired   = "expired"
+	validationExhausted = "exhausted"
+	validationError     = "error"
+)
+
+var tokenValidations = metrics.NewCounter("auth_token_validations_total",
+	"Validaciones de token por resultado: valid, missing, invalid, expired, exhausted (límites, cuota o créditos agotados) o error",
+	"outcome")
+
+// parseOutcome distingue los tokens caducados del resto de tokens inválidos
+func parseOutcome(err error) string {
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return validationExpired
+	}
+	return validationInvalid
+}
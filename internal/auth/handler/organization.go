@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+	"gorm.io/gorm"
+)
+
+// requireOrgAdmin verifica que el usuario sea administrador de la organización
+// de la ruta, o administrador global. Si no, escribe el error y devuelve false.
+func requireOrgAdmin(w http.ResponseWriter, r *http.Request) (int, *tokens.Claims, bool) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return 0, nil, false
+	}
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Id de organización inválido", nil)
+		return 0, nil, false
+	}
+	if claims.Role == models.RoleAdmin {
+		return orgID, claims, true
+	}
+	membership, err := service.GetMembership(claims.Subject)
+	if err != nil || membership.OrgID != orgID || membership.Role != models.OrgRoleAdmin {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Se requiere ser administrador de la organización", nil)
+		return 0, nil, false
+	}
+	return orgID, claims, true
+}
+
+// CreateOrganizationHandler crea una organización con el usuario como administrador
+func CreateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	if rejectImpersonation(w, claims) {
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Se requiere el nombre de la organización", nil)
+		return
+	}
+	username := claims.Subject
+	// Las organizaciones nuevas empiezan en el plan free; un administrador global lo puede cambiar
+	org, err := service.CreateOrganization(req.Name, "free", username)
+	if errors.Is(err, service.ErrAlreadyInOrg) {
+		sendJSONResponse(w, http.StatusConflict, "error", "Ya perteneces a una organización", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusConflict, "error", "La organización ya existe", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusCreated, "success", "Organización creada. Inicie sesión de nuevo para usar su cuota", org)
+}
+
+// MyOrganizationHandler devuelve la organización del usuario, sus miembros y el consumo
+func MyOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	username := claims.Subject
+	membership, err := service.GetMembership(username)
+	if err != nil {
+		sendJSONResponse(w, http.StatusNotFound, "error", "No perteneces a ninguna organización", nil)
+		return
+	}
+	remaining, err := service.OrgQuotaRemaining(membership.OrgID)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo la cuota", nil)
+		return
+	}
+	org, err := service.GetOrganization(membership.OrgID)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo la organización", nil)
+		return
+	}
+	members, err := service.ListMembers(org.ID)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo los miembros", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Organización obtenida", map[string]interface{}{
+		"organization":    org,
+		"role":            membership.Role,
+		"members":         members,
+		"quota":           service.PlanQuota(org.Plan),
+		"quota_remaining": remaining,
+	})
+}
+
+// AddMemberHandler invita a un usuario a la organización (administradores de
+// la organización). El usuario no se une ni consume la cuota hasta que acepta.
+func AddMemberHandler(w http.ResponseWriter, r *http.Request) {
+	orgID, claims, ok := requireOrgAdmin(w, r)
+	if !ok || rejectImpersonation(w, claims) {
+		return
+	}
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.OrgRoleMember
+	}
+	if req.Role != models.OrgRoleMember && req.Role != models.OrgRoleAdmin {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Rol inválido", nil)
+		return
+	}
+	actor := claims.Subject
+	invitation, err := service.InviteMember(orgID, req.Username, req.Role, actor)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado", nil)
+		return
+	}
+	if errors.Is(err, service.ErrAlreadyInOrg) {
+		sendJSONResponse(w, http.StatusConflict, "error", "El usuario ya pertenece a una organización", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error invitando al miembro", nil)
+		return
+	}
+	service.RecordAudit(req.Username, service.AuditOrgMemberInvited, "org:"+strconv.Itoa(orgID)+" by:"+actor, originalClientIP(r))
+	sendJSONResponse(w, http.StatusCreated, "success", "Invitación enviada. El usuario debe aceptarla para unirse", invitation)
+}
+
+// RemoveMemberHandler quita a un usuario de la organización (administradores de la organización)
+func RemoveMemberHandler(w http.ResponseWriter, r *http.Request) {
+	orgID, claims, ok := requireOrgAdmin(w, r)
+	if !ok || rejectImpersonation(w, claims) {
+		return
+	}
+	username := mux.Vars(r)["username"]
+	err := service.RemoveMember(orgID, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "El usuario no pertenece a la organización", nil)
+		return
+	}
+	if errors.Is(err, service.ErrLastOrgAdmin) {
+		sendJSONResponse(w, http.StatusConflict, "error", "La organización debe conservar al menos un administrador", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error quitando el miembro", nil)
+		return
+	}
+	actor := claims.Subject
+	service.RecordAudit(username, service.AuditOrgMemberRemoved, "org:"+strconv.Itoa(orgID)+" by:"+actor, originalClientIP(r))
+	sendJSONResponse(w, http.StatusOK, "success", "Miembro eliminado", nil)
+}
+
+// LeaveOrganizationHandler saca al usuario de su organización
+func LeaveOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	if rejectImpersonation(w, claims) {
+		return
+	}
+	username := claims.Subject
+	orgID, err := service.LeaveOrganization(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "No perteneces a ninguna organización", nil)
+		return
+	}
+	if errors.Is(err, service.ErrLastOrgAdmin) {
+		sendJSONResponse(w, http.StatusConflict, "error", "Eres el último administrador: nombra a otro antes de salir", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error saliendo de la organización", nil)
+		return
+	}
+	service.RecordAudit(username, service.AuditOrgMemberLeft, "org:"+strconv.Itoa(orgID), originalClientIP(r))
+	clearAuthCookie(w)
+	sendJSONResponse(w, http.StatusOK, "success", "Has salido de la organización. Inicie sesión de nuevo", nil)
+}
+
+// ListOrgInvitationsHandler devuelve las invitaciones pendientes del usuario
+func ListOrgInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	invitations, err := service.ListOrgInvitations(claims.Subject)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error obteniendo las invitaciones", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Invitaciones obtenidas", invitations)
+}
+
+// AcceptOrgInvitationHandler une al usuario a la organización que lo invitó
+func AcceptOrgInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	if rejectImpersonation(w, claims) {
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	username := claims.Subject
+	membership, err := service.AcceptOrgInvitation(username, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Invitación no encontrada o expirada", nil)
+		return
+	}
+	if errors.Is(err, service.ErrAlreadyInOrg) {
+		sendJSONResponse(w, http.StatusConflict, "error", "Ya perteneces a una organización", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error aceptando la invitación", nil)
+		return
+	}
+	service.RecordAudit(username, service.AuditOrgMemberAdded, "org:"+strconv.Itoa(membership.OrgID), originalClientIP(r))
+	sendJSONResponse(w, http.StatusOK, "success", "Te has unido a la organización. Inicie sesión de nuevo para usar su cuota", membership)
+}
+
+// DeclineOrgInvitationHandler rechaza una invitación pendiente
+func DeclineOrgInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err = service.DeclineOrgInvitation(claims.Subject, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Invitación no encontrada", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error rechazando la invitación", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Invitación rechazada", nil)
+}
+
+// SetOrganizationPlanHandler cambia el plan de una organización (solo administradores globales)
+func SetOrganizationPlanHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Id de organización inválido", nil)
+		return
+	}
+	var req struct {
+		Plan string `json:"plan"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !service.ValidPlan(req.Plan) {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "Plan inválido", nil)
+		return
+	}
+	err = service.SetOrganizationPlan(orgID, req.Plan)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Organización no encontrada", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error actualizando el plan", nil)
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Plan actualizado", nil)
+}
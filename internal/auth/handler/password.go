@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ChangePasswordHandler cambia la contraseña del usuario autenticado y revoca
+// todos sus tokens
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	claims, err := parseTokenFromRequest(r)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", err.Error(), nil)
+		return
+	}
+	if rejectImpersonation(w, claims) {
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+
+	username := claims.Subject
+	user, err := service.GetUserByUsername(username)
+	if err != nil {
+		sendJSONResponse(w, http.StatusNotFound, "error", "Usuario no encontrado", nil)
+		return
+	}
+	if user.Source != models.SourceLocal {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "La contraseña se gestiona en el backend "+user.Source, nil)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Contraseña actual incorrecta", nil)
+		return
+	}
+	if !checkPasswordNotBreached(w, req.NewPassword) {
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error en hash", nil)
+		return
+	}
+	if err := service.UpdatePassword(username, string(hash)); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error actualizando la contraseña", nil)
+		return
+	}
+	service.RecordAudit(username, service.AuditPasswordChanged, "", originalClientIP(r))
+	clearAuthCookie(w)
+	sendJSONResponse(w, http.StatusOK, "success", "Contraseña actualizada. Inicie sesión de nuevo", nil)
+}
+
+// checkPasswordNotBreached rechaza las contraseñas de la base local de
+// filtraciones. Devuelve false si ya respondió.
+func checkPasswordNotBreached(w http.ResponseWriter, password string) bool {
+	err := service.CheckPassword(password)
+	if errors.Is(err, service.ErrBreachedPassword) {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "La contraseña aparece en filtraciones conocidas. Elija otra", map[string]interface{}{
+			"error_code": "password_breached",
+		})
+		return false
+	}
+	if err != nil {
+		log.Printf("[AUTH] Error consultando la base de contraseñas filtradas: %v", err)
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error verificando la contraseña", nil)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxies son las redes del Gateway o de los proxies desde las que se
+// aceptan X-Forwarded-For y X-Original-*. Sin configurar no se confía en
+// nadie: cualquiera puede enviar esas cabeceras si llega al servicio.
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies configura las redes de confianza
+func SetTrustedProxies(networks []*net.IPNet) {
+	trustedProxies = networks
+}
+
+// ParseTrustedProxies interpreta TRUSTED_PROXIES: CIDR o IP sueltas separadas
+// por comas, por ejemplo "172.16.0.0/12,10.0.0.5"
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("IP de proxy inválida: %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("red de proxy inválida: %s", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy indica si la petición la envió el Gateway o un proxy de
+// confianza
+func fromTrustedProxy(r *http.Request) bool {
+	return isTrustedProxy(clientIP(r))
+}
+
+// originalClientIP devuelve la IP del cliente final. Solo se lee
+// X-Forwarded-For si la petición llega de un proxy de confianza, y se toma la
+// última dirección que no sea de un proxy: las anteriores las escribió el
+// propio cliente y se pueden falsificar.
+func originalClientIP(r *http.Request) string {
+	ip := clientIP(r)
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Una entrada inválida rompe la cadena: no se sigue más atrás
+			return ip
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		ip = hop
+	}
+	return ip
+}
+
+// proxyHeader devuelve una cabecera que solo puede fijar el Gateway, como
+// X-Original-URI, o vacía si la petición no llega de un proxy de confianza
+func proxyHeader(r *http.Request, name string) string {
+	if !fromTrustedProxy(r) {
+		return ""
+	}
+	return r.Header.Get(name)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginalClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("172.16.0.0/12, 10.0.0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTrustedProxies(proxies)
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"sin proxy no se lee X-Forwarded-For", "203.0.113.7:4000", "198.51.100.1", "203.0.113.7"},
+		{"desde el Gateway", "172.18.0.3:4000", "198.51.100.1", "198.51.100.1"},
+		{"la entrada del cliente se ignora", "172.18.0.3:4000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"cadena de proxies de confianza", "10.0.0.5:4000", "198.51.100.1, 172.18.0.3", "198.51.100.1"},
+		{"sin X-Forwarded-For", "172.18.0.3:4000", "", "172.18.0.3"},
+		{"entrada inválida", "172.18.0.3:4000", "no-es-una-ip", "172.18.0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := originalClientIP(r); got != tt.want {
+				t.Fatalf("originalClientIP = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyHeader(t *testing.T) {
+	proxies, _ := ParseTrustedProxies("172.16.0.0/12")
+	SetTrustedProxies(proxies)
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Original-URI", "/api/v1/characters")
+	r.RemoteAddr = "203.0.113.7:4000"
+	if got := proxyHeader(r, "X-Original-URI"); got != "" {
+		t.Fatalf("cabecera aceptada de un cliente directo: %q", got)
+	}
+	r.RemoteAddr = "172.18.0.3:4000"
+	if got := proxyHeader(r, "X-Original-URI"); got != "/api/v1/characters" {
+		t.Fatalf("cabecera del Gateway = %q", got)
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, value := range []string{"300.0.0.1", "10.0.0.0/40", "gateway"} {
+		if _, err := ParseTrustedProxies(value); err == nil {
+			t.Errorf("%q: se esperaba un error", value)
+		}
+	}
+}
@@ -0,0 +1,642 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"gorm.io/gorm"
+)
+
+const (
+	scimUserSchema   = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema  = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimPatchSchema  = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimErrorSchema  = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+// RequireSCIMToken protege los endpoints SCIM con el token estático de
+// SCIM_BEARER_TOKEN. Sin esa variable, SCIM queda deshabilitado.
+func RequireSCIMToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := os.Getenv("SCIM_BEARER_TOKEN")
+		if expected == "" {
+			sendSCIMError(w, http.StatusForbidden, "", "SCIM no está habilitado")
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if token == auth || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			sendSCIMError(w, http.StatusUnauthorized, "", "Token SCIM inválido")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func sendSCIM(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// sendSCIMError responde con el formato de error de RFC 7644 §3.12
+func sendSCIMError(w http.ResponseWriter, code int, scimType, detail string) {
+	body := map[string]interface{}{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(code),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	sendSCIM(w, code, body)
+}
+
+func scimLocation(r *http.Request, resource string, id int) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/scim/v2/%s/%d", scheme, r.Host, resource, id)
+}
+
+// scimPagination interpreta startIndex (base 1) y count según RFC 7644 §3.4.2.4
+func scimPagination(r *http.Request) (startIndex, count int) {
+	startIndex, count = 1, scimDefaultCount
+	if v, err := strconv.Atoi(r.URL.Query().Get("startIndex")); err == nil && v > 1 {
+		startIndex = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil {
+		count = v
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return startIndex, count
+}
+
+func sendSCIMList(w http.ResponseWriter, resources []interface{}, total int64, startIndex int) {
+	sendSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":      []string{scimListSchema},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func scimID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendSCIMError(w, http.StatusNotFound, "", "Recurso no encontrado")
+		return 0, false
+	}
+	return id, true
+}
+
+// scimBool acepta booleanos y los valores "True"/"False" que envían algunos proveedores
+func scimBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+type scimPatchRequest struct {
+	Schemas    []string `json:"schemas"`
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+func decodeSCIMPatch(w http.ResponseWriter, r *http.Request) (*scimPatchRequest, bool) {
+	var req scimPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Operations) == 0 {
+		sendSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Petición PATCH inválida")
+		return nil, false
+	}
+	if len(req.Schemas) != 1 || req.Schemas[0] != scimPatchSchema {
+		sendSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Se esperaba el esquema PatchOp")
+		return nil, false
+	}
+	return &req, true
+}
+
+// scimUser representa un usuario. El rol se expone como su grupo.
+func scimUser(r *http.Request, user *models.User) map[string]interface{} {
+	resource := map[string]interface{}{
+		"schemas":  []string{scimUserSchema},
+		"id":       strconv.Itoa(user.ID),
+		"userName": user.Username,
+		"active":   user.Active(),
+		"meta": map[string]interface{}{
+			"resourceType": "User",
+			"location":     scimLocation(r, "Users", user.ID),
+		},
+	}
+	if user.ExternalID != "" {
+		resource["externalId"] = user.ExternalID
+	}
+	if group, err := service.GetGroupByName(user.Role); err == nil {
+		resource["groups"] = []map[string]interface{}{{
+			"value":   strconv.Itoa(group.ID),
+			"display": group.DisplayName,
+			"$ref":    scimLocation(r, "Groups", group.ID),
+		}}
+	}
+	return resource
+}
+
+func scimGroup(r *http.Request, group *models.Group) (map[string]interface{}, error) {
+	users, err := service.GroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]map[string]interface{}, 0, len(users))
+	for _, user := range users {
+		members = append(members, map[string]interface{}{
+			"value":   strconv.Itoa(user.ID),
+			"display": user.Username,
+			"$ref":    scimLocation(r, "Users", user.ID),
+		})
+	}
+	return map[string]interface{}{
+		"schemas":     []string{scimGroupSchema},
+		"id":          strconv.Itoa(group.ID),
+		"displayName": group.DisplayName,
+		"members":     members,
+		"meta": map[string]interface{}{
+			"resourceType": "Group",
+			"created":      group.CreatedAt,
+			"location":     scimLocation(r, "Groups", group.ID),
+		},
+	}, nil
+}
+
+// SCIMListUsersHandler lista usuarios con filtro y paginación
+func SCIMListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := service.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		sendSCIMError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := scimPagination(r)
+	users, total, err := service.ListUsersPage(filter, startIndex-1, count)
+	if errors.Is(err, service.ErrUnsupportedFilter) {
+		sendSCIMError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	if err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error listando usuarios")
+		return
+	}
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, scimUser(r, &users[i]))
+	}
+	sendSCIMList(w, resources, total, startIndex)
+}
+
+// SCIMGetUserHandler devuelve un usuario
+func SCIMGetUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := scimID(w, r)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserByID(id)
+	if err != nil {
+		sendSCIMError(w, http.StatusNotFound, "", "Usuario no encontrado")
+		return
+	}
+	sendSCIM(w, http.StatusOK, scimUser(r, user))
+}
+
+// SCIMCreateUserHandler aprovisiona un usuario. La contraseña es opcional:
+// sin ella el usuario solo puede autenticarse con un backend externo.
+func SCIMCreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserName   string `json:"userName"`
+		ExternalID string `json:"externalId"`
+		Password   string `json:"password"`
+		Active     *bool  `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.UserName) == "" {
+		sendSCIMError(w, http.StatusBadRequest, "invalidValue", "userName es obligatorio")
+		return
+	}
+	if strings.HasPrefix(req.UserName, models.ServiceAccountPrefix) {
+		sendSCIMError(w, http.StatusBadRequest, "invalidValue", "userName no puede empezar por "+models.ServiceAccountPrefix)
+		return
+	}
+	if req.Password != "" {
+		err := service.CheckPassword(req.Password)
+		if errors.Is(err, service.ErrBreachedPassword) {
+			sendSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		if err != nil {
+			sendSCIMError(w, http.StatusInternalServerError, "", "Error verificando la contraseña")
+			return
+		}
+	}
+	user, err := service.CreateProvisionedUser(req.UserName, req.ExternalID, req.Password)
+	if errors.Is(err, service.ErrUserExists) {
+		sendSCIMError(w, http.StatusConflict, "uniqueness", "El usuario ya existe")
+		return
+	}
+	if err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error creando el usuario")
+		return
+	}
+	if req.Active != nil && !*req.Active {
+		service.DeactivateUser(user.Username)
+		user, _ = service.GetUserByID(user.ID)
+	}
+	service.RecordAudit(user.Username, service.AuditRegister, "scim", originalClientIP(r))
+	w.Header().Set("Location", scimLocation(r, "Users", user.ID))
+	sendSCIM(w, http.StatusCreated, scimUser(r, user))
+}
+
+// SCIMPatchUserHandler aplica operaciones PATCH sobre active y externalId.
+// userName es inmutable: identifica al usuario en los tokens y en el libro de créditos.
+func SCIMPatchUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := scimID(w, r)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserByID(id)
+	if err != nil {
+		sendSCIMError(w, http.StatusNotFound, "", "Usuario no encontrado")
+		return
+	}
+	req, ok := decodeSCIMPatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Se normalizan las operaciones a un conjunto de atributos a reemplazar
+	attributes := map[string]interface{}{}
+	for _, op := range req.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+		case "remove":
+			if strings.EqualFold(op.Path, "externalId") {
+				attributes["externalid"] = ""
+				continue
+			}
+			sendSCIMError(w, http.StatusBadRequest, "mutability", "Solo se puede eliminar externalId")
+			return
+		default:
+			sendSCIMError(w, http.StatusBadRequest, "invalidSyntax", "Operación no soportada: "+op.Op)
+			return
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			sendSCIMError(w, http.StatusBadRequest, "invalidValue", "Valor inválido")
+			return
+		}
+		if op.Path == "" {
+			object, isObject := value.(map[string]interface{})
+			if !isObject {
+				sendSCIMError(w, http.StatusBadRequest, "invalidValue", "Se esperaba un objeto sin path")
+				return
+			}
+			for k, v := range object {
+				attributes[strings.ToLower(k)] = v
+			}
+			continue
+		}
+		attributes[strings.ToLower(op.Path)] = value
+	}
+
+	fields := map[string]interface{}{}
+	var active *bool
+	for attribute, value := range attributes {
+		switch attribute {
+		case "active":
+			b, valid := scimBool(value)
+			if !valid {
+				sendSCIMError(w, http.StatusBadRequest, "invalidValue", "active debe ser booleano")
+				return
+			}
+			active = &b
+		case "externalid":
+			s, valid := value.(string)
+			if !valid {
+				sendSCIMError(w, http.StatusBadRequest, "invalidValue", "externalId debe ser texto")
+				return
+			}
+			fields["external_id"] = s
+		case "username":
+			if value != user.Username {
+				sendSCIMError(w, http.StatusBadRequest, "mutability", "userName no se puede modificar")
+				return
+			}
+		default:
+			sendSCIMError(w, http.StatusBadRequest, "invalidPath", "Atributo no soportado: "+attribute)
+			return
+		}
+	}
+
+	if err := service.UpdateUserFields(user, fields); err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error actualizando el usuario")
+		return
+	}
+	if active != nil && *active != user.Active() {
+		if *active {
+			err = service.ReactivateUser(user.Username)
+			service.RecordAudit(user.Username, service.AuditReactivate, "scim", originalClientIP(r))
+		} else {
+			err = service.DeactivateUser(user.Username)
+			service.RecordAudit(user.Username, service.AuditDeactivate, "scim", originalClientIP(r))
+		}
+		if err != nil {
+			sendSCIMError(w, http.StatusInternalServerError, "", "Error actualizando el usuario")
+			return
+		}
+	}
+	user, _ = service.GetUserByID(id)
+	sendSCIM(w, http.StatusOK, scimUser(r, user))
+}
+
+// SCIMDeleteUserHandler elimina un usuario y revoca sus tokens
+func SCIMDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := scimID(w, r)
+	if !ok {
+		return
+	}
+	user, err := service.GetUserByID(id)
+	if err != nil {
+		sendSCIMError(w, http.StatusNotFound, "", "Usuario no encontrado")
+		return
+	}
+	if err := service.DeleteUser(user.Username); err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error eliminando el usuario")
+		return
+	}
+	service.RecordAudit(user.Username, service.AuditDeactivate, "scim delete", originalClientIP(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SCIMListGroupsHandler lista grupos con filtro y paginación
+func SCIMListGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := service.ParseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		sendSCIMError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	startIndex, count := scimPagination(r)
+	groups, total, err := service.ListGroupsPage(filter, startIndex-1, count)
+	if errors.Is(err, service.ErrUnsupportedFilter) {
+		sendSCIMError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+		return
+	}
+	if err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error listando grupos")
+		return
+	}
+	resources := make([]interface{}, 0, len(groups))
+	for i := range groups {
+		resource, err := scimGroup(r, &groups[i])
+		if err != nil {
+			sendSCIMError(w, http.StatusInternalServerError, "", "Error listando grupos")
+			return
+		}
+		resources = append(resources, resource)
+	}
+	sendSCIMList(w, resources, total, startIndex)
+}
+
+func loadSCIMGroup(w http.ResponseWriter, r *http.Request) (*models.Group, bool) {
+	id, ok := scimID(w, r)
+	if !ok {
+		return nil, false
+	}
+	group, err := service.GetGroup(id)
+	if err != nil {
+		sendSCIMError(w, http.StatusNotFound, "", "Grupo no encontrado")
+		return nil, false
+	}
+	return group, true
+}
+
+func sendSCIMGroup(w http.ResponseWriter, r *http.Request, code int, group *models.Group) {
+	resource, err := scimGroup(r, group)
+	if err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error leyendo el grupo")
+		return
+	}
+	sendSCIM(w, code, resource)
+}
+
+// SCIMGetGroupHandler devuelve un grupo con sus miembros
+func SCIMGetGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if group, ok := loadSCIMGroup(w, r); ok {
+		sendSCIMGroup(w, r, http.StatusOK, group)
+	}
+}
+
+type scimMember struct {
+	Value string `json:"value"`
+}
+
+func scimMemberIDs(members []scimMember) ([]int, error) {
+	ids := make([]int, 0, len(members))
+	for _, m := range members {
+		id, err := strconv.Atoi(m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("miembro inválido: %s", m.Value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SCIMCreateGroupHandler crea un grupo, que equivale a un rol nuevo
+func SCIMCreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DisplayName string       `json:"displayName"`
+		Members     []scimMember `json:"members"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.DisplayName) == "" {
+		sendSCIMError(w, http.StatusBadRequest, "invalidValue", "displayName es obligatorio")
+		return
+	}
+	if req.DisplayName == models.RoleService {
+		sendSCIMError(w, http.StatusBadRequest, "invalidValue", "displayName reservado")
+		return
+	}
+	ids, err := scimMemberIDs(req.Members)
+	if err != nil {
+		sendSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	if _, err := service.GetGroupByName(req.DisplayName); err == nil {
+		sendSCIMError(w, http.StatusConflict, "uniqueness", "El grupo ya existe")
+		return
+	}
+	group, err := service.CreateGroup(req.DisplayName)
+	if err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error creando el grupo")
+		return
+	}
+	if err := service.SetGroupMembership(group, ids, true); err != nil {
+		sendSCIMError(w, http.StatusInternalServerError, "", "Error asignando miembros")
+		return
+	}
+	w.Header().Set("Location", scimLocation(r, "Groups", group.ID))
+	sendSCIMGroup(w, r, http.StatusCreated, group)
+}
+
+// SCIMPatchGroupHandler agrega, quita o reemplaza miembros y renombra el grupo
+func SCIMPatchGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group, ok := loadSCIMGroup(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeSCIMPatch(w, r)
+	if !ok {
+		return
+	}
+
+	for _, op := range req.Operations {
+		opName := strings.ToLower(op.Op)
+		path := strings.ToLower(op.Path)
+		var err error
+		switch {
+		// remove con filtro de valor: members[value eq "3"]
+		case opName == "remove" && strings.HasPrefix(path, "members["):
+			var filter *service.Filter
+			filter, err = service.ParseFilter(strings.TrimSuffix(op.Path[len("members["):], "]"))
+			if err != nil || filter == nil || filter.Operator != "eq" || !strings.EqualFold(filter.Attribute, "value") {
+				sendSCIMError(w, http.StatusBadRequest, "invalidFilter", "Filtro de miembros no soportado")
+				return
+			}
+			var id int
+			if id, err = strconv.Atoi(filter.Value); err == nil {
+				err = service.SetGroupMembership(group, []int{id}, false)
+			}
+		case path == "members":
+			var members []scimMember
+			if opName != "remove" || len(op.Value) > 0 {
+				if err := json.Unmarshal(op.Value, &members); err != nil {
+					sendSCIMError(w, http.StatusBadRequest, "invalidValue", "members debe ser una lista")
+					return
+				}
+			}
+			err = applySCIMMembers(group, opName, members)
+		case path == "displayname" && opName == "replace":
+			var name string
+			if err := json.Unmarshal(op.Value, &name); err != nil || name == "" {
+				sendSCIMError(w, http.StatusBadRequest, "invalidValue", "displayName inválido")
+				return
+			}
+			err = renameSCIMGroup(group, name)
+		case path == "" && (opName == "add" || opName == "replace"):
+			var value struct {
+				DisplayName string       `json:"displayName"`
+				Members     []scimMember `json:"members"`
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				sendSCIMError(w, http.StatusBadRequest, "invalidValue", "Valor inválido")
+				return
+			}
+			if value.DisplayName != "" {
+				err = renameSCIMGroup(group, value.DisplayName)
+			}
+			if err == nil && value.Members != nil {
+				err = applySCIMMembers(group, opName, value.Members)
+			}
+		default:
+			sendSCIMError(w, http.StatusBadRequest, "invalidPath", "Operación no soportada")
+			return
+		}
+		if err != nil {
+			sendSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	sendSCIMGroup(w, r, http.StatusOK, group)
+}
+
+func applySCIMMembers(group *models.Group, op string, members []scimMember) error {
+	ids, err := scimMemberIDs(members)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case "add":
+		return service.SetGroupMembership(group, ids, true)
+	case "remove":
+		// remove sin valor vacía el grupo
+		if len(members) == 0 {
+			return removeAllSCIMMembers(group)
+		}
+		return service.SetGroupMembership(group, ids, false)
+	case "replace":
+		if err := removeAllSCIMMembers(group); err != nil {
+			return err
+		}
+		return service.SetGroupMembership(group, ids, true)
+	}
+	return fmt.Errorf("operación no soportada: %s", op)
+}
+
+func removeAllSCIMMembers(group *models.Group) error {
+	current, err := service.GroupMembers(group)
+	if err != nil {
+		return err
+	}
+	ids := make([]int, 0, len(current))
+	for _, user := range current {
+		ids = append(ids, user.ID)
+	}
+	return service.SetGroupMembership(group, ids, false)
+}
+
+func renameSCIMGroup(group *models.Group, name string) error {
+	if name == group.DisplayName {
+		return nil
+	}
+	if group.DisplayName == models.RoleUser || group.DisplayName == models.RoleAdmin {
+		return errors.New("no se puede renombrar un grupo predefinido")
+	}
+	if _, err := service.GetGroupByName(name); !errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("ya existe un grupo con ese nombre")
+	}
+	return service.RenameGroup(group, name)
+}
+
+// SCIMDeleteGroupHandler elimina un grupo; sus miembros vuelven al rol user
+func SCIMDeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	group, ok := loadSCIMGroup(w, r)
+	if !ok {
+		return
+	}
+	if err := service.DeleteGroup(group); err != nil {
+		sendSCIMError(w, http.StatusBadRequest, "mutability", err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
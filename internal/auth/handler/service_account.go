@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+)
+
+// ServiceAccountTokenHandler emite un token a una cuenta de servicio con el
+// grant client_credentials de OAuth 2.0. Las credenciales se aceptan en el
+// cuerpo o con autenticación Basic. Las cuentas de servicio no tienen
+// dispositivos ni un usuario al que avisar, así que no se evalúan anomalías.
+func ServiceAccountTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("grant_type") != "client_credentials" {
+		sendOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Solo se admite client_credentials")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	account, err := service.AuthenticateServiceAccount(clientID, clientSecret)
+	if errors.Is(err, service.ErrInvalidClientSecret) {
+		service.RecordAudit(models.ServiceAccountPrefix+clientID, service.AuditLoginFailed, "client_credentials", originalClientIP(r))
+		sendOAuthError(w, http.StatusUnauthorized, "invalid_client", "Cuenta de servicio o secreto inválido")
+		return
+	}
+	if err != nil {
+		sendOAuthError(w, http.StatusInternalServerError, "server_error", "Error verificando la cuenta de servicio")
+		return
+	}
+
+	// Las cuentas de servicio usan el mismo libro y auditoría que los usuarios,
+	// con el sujeto sa:<nombre>
+	principal := &models.User{Username: account.Subject(), Role: models.RoleService, Plan: account.Plan}
+	tokenString, err := issueToken(principal, tokenBinding{})
+	if err != nil {
+		sendOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	service.RecordAudit(principal.Username, service.AuditLogin, "client_credentials", originalClientIP(r))
+	sendOAuthJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": tokenString,
+		"token_type":   "Bearer",
+		"expires_in":   int(service.TokenMaxLifetime().Seconds()),
+	})
+}
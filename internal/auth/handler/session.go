@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+)
+
+// LogoutHandler revoca el token de la sesión y elimina la cookie. Responde con
+// éxito aunque no haya sesión para que cerrar sesión sea idempotente.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	tokenString, ok := tokenFromRequest(r)
+	if ok {
+		if err := service.RevokeToken(tokenString); err != nil {
+			sendJSONResponse(w, http.StatusInternalServerError, "error", "Error revocando el token", nil)
+			return
+		}
+		if claims, err := tokens.ConfigFromEnv().Parse(tokenString); err == nil {
+			service.RecordAudit(claims.Subject, service.AuditLogout, "", originalClientIP(r))
+		}
+	}
+	clearAuthCookie(w)
+	sendJSONResponse(w, http.StatusOK, "success", "Sesión cerrada", nil)
+}
+
+// RefreshHandler cambia un token vigente por uno nuevo y revoca el anterior.
+// El token nuevo conserva el ligado al cliente y la expiración absoluta del
+// anterior: renovar reinicia la ventana de inactividad pero nunca alarga la
+// sesión más allá de TOKEN_MAX_LIFETIME_HOURS desde el login. Por eso la
+// renovación no vuelve a evaluar anomalías: continúa una sesión que ya pasó
+// esas comprobaciones al iniciarse.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	tokenString, ok := tokenFromRequest(r)
+	if !ok {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token no encontrado en cookie", nil)
+		return
+	}
+	claims, err := tokens.ConfigFromEnv().Parse(tokenString)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token inválido", nil)
+		return
+	}
+	// El margen de reloj de Parse no sirve para renovar un token ya caducado
+	if !claims.ExpiresAt.After(time.Now()) {
+		clearAuthCookie(w)
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado", nil)
+		return
+	}
+	// Los tokens de suplantación tienen una duración corta que no se puede extender
+	if claims.Actor != nil {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Los tokens de suplantación no se pueden renovar", nil)
+		return
+	}
+
+	modes, fingerprint, err := service.TokenBinding(tokenString)
+	if errors.Is(err, service.ErrTokenNotFound) {
+		clearAuthCookie(w)
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado", nil)
+		return
+	}
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error validando el token", nil)
+		return
+	}
+	if fingerprint != "" && requestBinding(r, modes).Fingerprint != fingerprint {
+		detail := "modes:" + modes + " ua:" + r.UserAgent()
+		service.RecordAudit(claims.Subject, service.AuditTokenBindingMismatch, detail, originalClientIP(r))
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token usado desde un cliente distinto al que lo obtuvo", map[string]interface{}{
+			"error_code": "token_binding_mismatch",
+		})
+		return
+	}
+	// Un token inactivo no se puede renovar: hay que volver a iniciar sesión
+	if _, err := service.ExtendTokenIdle(tokenString); err != nil {
+		if errors.Is(err, service.ErrTokenNotFound) || errors.Is(err, service.ErrTokenIdle) {
+			clearAuthCookie(w)
+			sendJSONResponse(w, http.StatusUnauthorized, "error", "Token expirado", nil)
+			return
+		}
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error validando el token", nil)
+		return
+	}
+
+	user, err := service.GetUserByUsername(claims.Subject)
+	if err != nil {
+		sendJSONResponse(w, http.StatusUnauthorized, "error", "Usuario no encontrado", nil)
+		return
+	}
+	if !user.Active() {
+		sendJSONResponse(w, http.StatusForbidden, "error", "Cuenta desactivada", nil)
+		return
+	}
+
+	binding := tokenBinding{Modes: modes, Fingerprint: fingerprint}
+	newToken, err := issueTokenWithClaims(user, claims.ExpiresAt.Time, nil, binding)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", err.Error(), nil)
+		return
+	}
+	if err := service.RevokeToken(tokenString); err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error revocando el token anterior", nil)
+		return
+	}
+	service.RecordAudit(user.Username, service.AuditTokenRefreshed, "", originalClientIP(r))
+	setAuthCookie(w, newToken)
+	sendJSONResponse(w, http.StatusOK, "success", "Token renovado", map[string]interface{}{
+		"username": user.Username,
+		"message":  "Token guardado en cookie",
+	})
+}
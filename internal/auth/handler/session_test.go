@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+)
+
+func TestRefreshKeepsAbsoluteExpiry(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("TOKEN_MAX_LIFETIME_HOURS", "1")
+	t.Setenv("TOKEN_IDLE_TIMEOUT_MINUTES", "30")
+	user := createUser(t, "rick", "user")
+
+	// Sesión iniciada hace 50 minutos: le quedan 10 de su límite absoluto
+	original := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	token, err := issueTokenWithClaims(user, original, nil, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		w, resp := serve(t, RefreshHandler, "POST", "/api/v1/refresh", token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("renovación %d: %d %s", i, w.Code, resp.Message)
+		}
+		previous := token
+		token = authCookie(t, w)
+		claims, err := tokens.ConfigFromEnv().Parse(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !claims.ExpiresAt.Time.Equal(original) {
+			t.Fatalf("renovación %d: exp = %v, se esperaba %v", i, claims.ExpiresAt.Time, original)
+		}
+		// El token anterior queda revocado
+		if w, _ := serve(t, RefreshHandler, "POST", "/api/v1/refresh", previous, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("el token anterior se pudo renovar: %d", w.Code)
+		}
+	}
+}
+
+func TestRefreshRejectsExpiredToken(t *testing.T) {
+	setupTestDB(t)
+	user := createUser(t, "rick", "user")
+	// Caducado hace 10 segundos: dentro del margen de reloj de Parse
+	token, err := issueTokenWithClaims(user, time.Now().Add(-10*time.Second), nil, tokenBinding{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, resp := serve(t, RefreshHandler, "POST", "/api/v1/refresh", token, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("se renovó un token caducado: %d %s", w.Code, resp.Message)
+	}
+}
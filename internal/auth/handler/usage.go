@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/service"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+	"github.com/yourusername/api_ricky_and_morty/internal/usage"
+)
+
+// usageBatchEntry son las peticiones que el Gateway atendió con un token
+// validado localmente y aún no contabilizó
+type usageBatchEntry struct {
+	Token       string `json:"token"`
+	Count       int    `json:"count"`
+	Description string `json:"description"`
+}
+
+// usageBatchResult indica cuántas peticiones se contabilizaron y, si se
+// rechazó alguna, el motivo. El Gateway deja de usar su caché para los tokens
+// con rechazos y vuelve a validarlos de forma síncrona.
+type usageBatchResult struct {
+	Accepted int    `json:"accepted"`
+	Rejected string `json:"rejected,omitempty"`
+}
+
+// UsageBatchHandler contabiliza en lote las peticiones que el Gateway atendió
+// con su caché de validación: ventanas de uso, cuota de la organización y
+// créditos, igual que /validate
+func UsageBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireInternal(w, r, "Solo el Gateway puede reportar uso") {
+		return
+	}
+	var req struct {
+		Entries []usageBatchEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONResponse(w, http.StatusBadRequest, "error", "JSON inválido", nil)
+		return
+	}
+	if len(req.Entries) > usage.MaxBatchEntries {
+		sendJSONResponse(w, http.StatusRequestEntityTooLarge, "error", "Demasiadas entradas en el lote", nil)
+		return
+	}
+	config := tokens.ConfigFromEnv()
+	results := make([]usageBatchResult, len(req.Entries))
+	for i, entry := range req.Entries {
+		results[i] = recordBatchUsage(config, entry)
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Uso registrado", map[string]interface{}{
+		"results": results,
+	})
+}
+
+func recordBatchUsage(config tokens.Config, entry usageBatchEntry) usageBatchResult {
+	if entry.Count <= 0 || entry.Count > usage.MaxBatchCount {
+		return usageBatchResult{Rejected: "invalid_count"}
+	}
+	claims, err := config.Parse(entry.Token)
+	if err != nil {
+		return usageBatchResult{Rejected: "invalid_token"}
+	}
+	if _, err := service.ExtendTokenIdle(entry.Token); err != nil {
+		if errors.Is(err, service.ErrTokenNotFound) || errors.Is(err, service.ErrTokenIdle) {
+			return usageBatchResult{Rejected: "token_expired"}
+		}
+		return usageBatchResult{Rejected: "error"}
+	}
+
+	username := claims.Subject
+	result := usageBatchResult{}
+	for ; result.Accepted < entry.Count; result.Accepted++ {
+		_, err := service.ChargeRequest(username, claims.Plan, claims.Org, entry.Description, time.Now())
+		switch {
+		case errors.Is(err, service.ErrRateLimited):
+			result.Rejected = "rate_limited"
+		case errors.Is(err, service.ErrQuotaExceeded):
+			result.Rejected = "org_quota_exceeded"
+		case errors.Is(err, service.ErrInsufficientCredits):
+			result.Rejected = "insufficient_credits"
+		case err != nil:
+			result.Rejected = "error"
+		}
+		if err != nil {
+			return result
+		}
+	}
+	return result
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AuditEvent registra una acción relevante para la seguridad
+type AuditEvent struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	Username  string    `gorm:"index" json:"username"`
+	Action    string    `gorm:"index;not null" json:"action"`
+	Detail    string    `json:"detail,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Estados de una autorización de dispositivo
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusApproved = "approved"
+	DeviceStatusDenied   = "denied"
+	DeviceStatusConsumed = "consumed"
+)
+
+// DeviceAuthorization es una solicitud del flujo de autorización de
+// dispositivos (RFC 8628). El device code se guarda como hash.
+type DeviceAuthorization struct {
+	ID             int    `gorm:"primaryKey"`
+	DeviceCodeHash string `gorm:"unique;not null"`
+	UserCode       string `gorm:"unique;not null"`
+	ClientID       string `gorm:"not null"`
+	Status         string `gorm:"not null;default:pending"`
+	Username       string
+	Interval       int `gorm:"not null"`
+	LastPolledAt   *time.Time
+	ExpiresAt      time.Time `gorm:"index;not null"`
+	CreatedAt      time.Time
+}
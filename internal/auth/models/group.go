@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Group es un grupo SCIM. Cada grupo corresponde a un rol: los miembros del
+// grupo son los usuarios cuyo Role coincide con DisplayName.
+type Group struct {
+	ID          int    `gorm:"primaryKey"`
+	DisplayName string `gorm:"unique;not null"`
+	CreatedAt   time.Time
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Invite es un código de invitación que permite registrarse cuando el
+// registro no está abierto. Puede tener varios usos y una fecha de expiración.
+type Invite struct {
+	ID        int       `gorm:"primaryKey"`
+	Code      string    `gorm:"unique;not null"`
+	MaxUses   int       `gorm:"not null;default:1"`
+	Uses      int       `gorm:"not null;default:0"`
+	Role      string    `gorm:"not null;default:user"`
+	Plan      string    `gorm:"not null;default:free"`
+	CreatedBy string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Tipos de movimiento del libro de créditos
+const (
+	LedgerGrant  = "grant"
+	LedgerTopUp  = "topup"
+	LedgerDebit  = "debit"
+	LedgerRefund = "refund"
+)
+
+// Cuentas del sistema que actúan como contrapartida de las cuentas de usuario
+const (
+	AccountGrants = "system:grants"
+	AccountUsage  = "system:usage"
+)
+
+// LedgerEntry es un apunte del libro de créditos de partida doble. Cada
+// transacción (TxID) tiene dos apuntes cuyos importes suman cero.
+type LedgerEntry struct {
+	ID          int       `gorm:"primaryKey" json:"-"`
+	TxID        string    `gorm:"index;not null" json:"tx_id"`
+	Account     string    `gorm:"index;not null" json:"account"`
+	Amount      int       `gorm:"not null" json:"amount"`
+	Kind        string    `gorm:"index;not null" json:"kind"`
+	Reference   string    `gorm:"index" json:"reference,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// UserAccount devuelve la cuenta del libro asociada a un usuario
+func UserAccount(username string) string {
+	return "user:" + username
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// KnownDevice es una combinación de dispositivo e IP desde la que el usuario
+// ya inició sesión. Fingerprint es el hash del User-Agent o de la clave del cliente.
+type KnownDevice struct {
+	ID          int    `gorm:"primaryKey"`
+	Username    string `gorm:"index;not null"`
+	Fingerprint string `gorm:"not null"`
+	IP          string `gorm:"not null"`
+	HasLocation bool
+	Latitude    float64
+	Longitude   float64
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+}
+
+// LoginChallenge es un login sospechoso pendiente de un segundo factor
+type LoginChallenge struct {
+	ID          string `gorm:"primaryKey"`
+	Username    string `gorm:"index;not null"`
+	CodeHash    string `gorm:"not null"`
+	Fingerprint string
+	IP          string
+	Attempts    int
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
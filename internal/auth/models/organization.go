@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Roles dentro de una organización
+const (
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// Organization agrupa usuarios que comparten un plan y una cuota mensual de peticiones
+type Organization struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"unique;not null" json:"name"`
+	Plan        string    `gorm:"not null;default:free" json:"plan"`
+	Used        int       `gorm:"not null;default:0" json:"used"`
+	PeriodStart time.Time `gorm:"not null" json:"period_start"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OrgMembership asocia un usuario a una organización. Un usuario pertenece
+// como máximo a una organización.
+type OrgMembership struct {
+	ID        int       `gorm:"primaryKey" json:"-"`
+	OrgID     int       `gorm:"index;not null" json:"org_id"`
+	Username  string    `gorm:"unique;not null" json:"username"`
+	Role      string    `gorm:"not null;default:member" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrgInvitation es una invitación pendiente a una organización. El usuario
+// solo pasa a ser miembro cuando la acepta.
+type OrgInvitation struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	OrgID     int       `gorm:"uniqueIndex:idx_org_invitation;not null" json:"org_id"`
+	Username  string    `gorm:"uniqueIndex:idx_org_invitation;not null" json:"username"`
+	Role      string    `gorm:"not null;default:member" json:"role"`
+	InvitedBy string    `gorm:"not null" json:"invited_by"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	// RoleService es el rol de los tokens emitidos a cuentas de servicio
+	RoleService = "service"
+	// ServiceAccountPrefix distingue el sujeto de las cuentas de servicio del de los usuarios
+	ServiceAccountPrefix = "sa:"
+)
+
+// ServiceAccount es un principal no humano para procesos batch
+type ServiceAccount struct {
+	ID          int       `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"unique;not null" json:"name"`
+	Description string    `json:"description,omitempty"`
+	Plan        string    `gorm:"not null;default:free" json:"plan"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Subject devuelve el sujeto con el que la cuenta aparece en tokens, libro y auditoría
+func (a *ServiceAccount) Subject() string {
+	return ServiceAccountPrefix + a.Name
+}
+
+// ServiceAccountSecret es uno de los secretos de una cuenta de servicio. Puede
+// haber varios vigentes a la vez durante el período de gracia de una rotación.
+type ServiceAccountSecret struct {
+	ID         int        `gorm:"primaryKey" json:"id"`
+	AccountID  int        `gorm:"index;not null" json:"-"`
+	Prefix     string     `gorm:"unique;not null" json:"prefix"`
+	Hash       string     `gorm:"not null" json:"-"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Token registra un JWT emitido para poder revocarlo, ligarlo al cliente y
+// expirarlo por inactividad. Se almacena como hash SHA-256, nunca en claro.
+type Token struct {
+	ID       int    `gorm:"primaryKey"`
+	Hash     string `gorm:"unique;not null"`
+	Username string `gorm:"index;not null"`
+	// Ligado opcional del token al cliente que lo obtuvo
+	BindingModes string
+	Fingerprint  string
+	// ExpiresAt es el límite absoluto; IdleExpiresAt se extiende con cada
+	// validación y es nil si no hay timeout de inactividad
+	ExpiresAt     time.Time `gorm:"index;not null"`
+	IdleExpiresAt *time.Time
+	CreatedAt     time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UsageWindow cuenta las peticiones de un usuario en la ventana fija actual.
+// Start es el inicio de la ventana; al cruzar el límite el contador se reinicia.
+type UsageWindow struct {
+	ID       int       `gorm:"primaryKey"`
+	Username string    `gorm:"uniqueIndex:idx_usage_window;not null"`
+	Window   string    `gorm:"uniqueIndex:idx_usage_window;not null"`
+	Start    time.Time `gorm:"not null"`
+	Count    int       `gorm:"not null"`
+}
@@ -1,7 +1,29 @@
 package models
 
+import "time"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+
+	// SourceLocal identifica a los usuarios con contraseña en la base de datos
+	SourceLocal = "local"
+	// SourceSCIM identifica a los usuarios aprovisionados por SCIM sin contraseña local
+	SourceSCIM = "scim"
+)
+
 type User struct {
-	ID       int    `gorm:"primaryKey"`
-	Username string `gorm:"unique;not null"`
-	Password string `gorm:"not null"`
+	ID            int        `gorm:"primaryKey"`
+	Username      string     `gorm:"unique;not null"`
+	Password      string     `gorm:"not null"`
+	Role          string     `gorm:"not null;default:user"`
+	Plan          string     `gorm:"not null;default:free"`
+	Source        string     `gorm:"not null;default:local"`
+	ExternalID    string     `gorm:"index"`
+	DeactivatedAt *time.Time `gorm:"index"`
+}
+
+// Active indica si la cuenta no ha sido desactivada
+func (u *User) Active() bool {
+	return u.DeactivatedAt == nil
 }
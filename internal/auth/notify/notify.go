@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Notification es un aviso de seguridad dirigido a un usuario
+type Notification struct {
+	Username string            `json:"username"`
+	Kind     string            `json:"kind"`
+	Message  string            `json:"message"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	// Code es un código de verificación. Solo lo reciben los destinos que
+	// llegan al usuario; el log lo oculta.
+	Code   string    `json:"code,omitempty"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// ErrNoUserChannel indica que ningún destino hizo llegar al usuario una
+// notificación con código
+var ErrNoUserChannel = errors.New("no hay un destino que llegue al usuario para enviar el código")
+
+const (
+	// KindNewDevice avisa de un login desde un dispositivo o IP desconocidos
+	KindNewDevice = "new_device"
+	// KindStepUpCode entrega el código de verificación de un login sospechoso
+	KindStepUpCode = "step_up_code"
+)
+
+// Notifier entrega notificaciones a un destino concreto
+type Notifier interface {
+	Name() string
+	Notify(n Notification) error
+}
+
+// UserFacing lo implementan los destinos que hacen llegar la notificación al
+// propio usuario (correo, chat, SMS), los únicos que reciben los códigos
+type UserFacing interface {
+	UserFacing() bool
+}
+
+func isUserFacing(n Notifier) bool {
+	u, ok := n.(UserFacing)
+	return ok && u.UserFacing()
+}
+
+// Multi entrega cada notificación a todos sus destinos
+type Multi []Notifier
+
+func (m Multi) Name() string {
+	names := make([]string, len(m))
+	for i, n := range m {
+		names[i] = n.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// UserFacing indica si algún destino llega al usuario
+func (m Multi) UserFacing() bool {
+	for _, notifier := range m {
+		if isUserFacing(notifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify intenta todos los destinos y devuelve el primer error. Una
+// notificación con código solo se da por entregada si al menos un destino
+// que llega al usuario la aceptó; si no, devuelve ErrNoUserChannel.
+func (m Multi) Notify(n Notification) error {
+	var first error
+	delivered := false
+	for _, notifier := range m {
+		if err := notifier.Notify(n); err != nil {
+			log.Printf("[AUTH] Error en el notificador %s: %v", notifier.Name(), err)
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		if isUserFacing(notifier) {
+			delivered = true
+		}
+	}
+	if n.Code != "" && !delivered {
+		if first != nil {
+			return first
+		}
+		return ErrNoUserChannel
+	}
+	return first
+}
+
+// Log escribe las notificaciones en el log del servicio
+type Log struct{}
+
+func (Log) Name() string { return "log" }
+
+// Notify nunca escribe el código: el log lo leen operadores, no el usuario
+func (Log) Notify(n Notification) error {
+	code := ""
+	if n.Code != "" {
+		code = " código:[oculto]"
+	}
+	log.Printf("[AUTH] Notificación %s para %s: %s %v%s", n.Kind, n.Username, n.Message, n.Fields, code)
+	return nil
+}
+
+// FromEnv construye los destinos a partir de NOTIFIERS (por defecto "log")
+func FromEnv() (Multi, error) {
+	sinks := os.Getenv("NOTIFIERS")
+	if sinks == "" {
+		sinks = "log"
+	}
+	var multi Multi
+	for _, name := range strings.Split(sinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "log":
+			multi = append(multi, Log{})
+		case "webhook":
+			url := os.Getenv("NOTIFIER_WEBHOOK_URL")
+			if url == "" {
+				return nil, errors.New("NOTIFIER_WEBHOOK_URL es obligatorio para el notificador webhook")
+			}
+			multi = append(multi, NewWebhook(url, os.Getenv("NOTIFIER_WEBHOOK_SECRET")))
+		case "":
+		default:
+			return nil, errors.New("notificador desconocido: " + name)
+		}
+	}
+	return multi, nil
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// userChannel simula un destino que llega al usuario
+type userChannel struct {
+	err      error
+	received []Notification
+}
+
+func (u *userChannel) Name() string     { return "user" }
+func (u *userChannel) UserFacing() bool { return true }
+func (u *userChannel) Notify(n Notification) error {
+	u.received = append(u.received, n)
+	return u.err
+}
+
+func TestMultiNotifyCode(t *testing.T) {
+	failing := errors.New("smtp caído")
+	tests := []struct {
+		name    string
+		multi   func() (Multi, *userChannel)
+		code    string
+		wantErr error
+	}{
+		{"aviso sin código solo al log", func() (Multi, *userChannel) { return Multi{Log{}}, nil }, "", nil},
+		{"código sin destino de usuario", func() (Multi, *userChannel) { return Multi{Log{}}, nil }, "123456", ErrNoUserChannel},
+		{"código entregado", func() (Multi, *userChannel) {
+			u := &userChannel{}
+			return Multi{Log{}, u}, u
+		}, "123456", nil},
+		{"el destino de usuario falla", func() (Multi, *userChannel) {
+			u := &userChannel{err: failing}
+			return Multi{Log{}, u}, u
+		}, "123456", failing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			multi, user := tt.multi()
+			err := multi.Notify(Notification{Username: "rick", Kind: KindStepUpCode, Code: tt.code})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			if user != nil && (len(user.received) != 1 || user.received[0].Code != tt.code) {
+				t.Fatalf("el destino de usuario recibió %+v", user.received)
+			}
+		})
+	}
+}
+
+func TestLogHidesCode(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	Log{}.Notify(Notification{Username: "rick", Kind: KindStepUpCode, Code: "482913", Fields: map[string]string{"ip": "203.0.113.7"}})
+	if strings.Contains(buf.String(), "482913") {
+		t.Fatalf("el log contiene el código: %s", buf.String())
+	}
+}
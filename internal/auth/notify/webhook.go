@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook envía cada notificación como JSON por POST. Con un secreto, el cuerpo
+// se firma con HMAC-SHA256 en la cabecera X-Signature para que el receptor
+// (correo, chat, SMS) pueda verificar el origen.
+type Webhook struct {
+	URL    string
+	Secret string
+	client *http.Client
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{URL: url, Secret: secret, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+// UserFacing: el receptor del webhook entrega la notificación al usuario
+func (w *Webhook) UserFacing() bool { return true }
+
+func (w *Webhook) Notify(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("el webhook respondió %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+const (
+	AnomalyNewDevice        = "new_device"
+	AnomalyNewIP            = "new_ip"
+	AnomalyImpossibleTravel = "impossible_travel"
+
+	loginChallengeTTL         = 10 * time.Minute
+	loginChallengeMaxAttempts = 5
+)
+
+var (
+	ErrChallengeInvalid   = errors.New("desafío inválido o expirado")
+	ErrChallengeExhausted = errors.New("demasiados intentos para el desafío")
+)
+
+// LoginSignal describe desde dónde se intenta iniciar sesión
+type LoginSignal struct {
+	Username    string
+	Fingerprint string
+	IP          string
+	At          time.Time
+}
+
+// MaxTravelSpeed es la velocidad (km/h) por encima de la cual dos logins
+// consecutivos se consideran un viaje imposible. Se configura con LOGIN_MAX_SPEED_KMH.
+func MaxTravelSpeed() float64 {
+	speed, err := strconv.ParseFloat(os.Getenv("LOGIN_MAX_SPEED_KMH"), 64)
+	if err != nil || speed <= 0 {
+		return 1000
+	}
+	return speed
+}
+
+// AssessLogin compara el login con los dispositivos conocidos del usuario y
+// devuelve las anomalías detectadas. El primer login no tiene referencia y no
+// se marca como anómalo.
+func AssessLogin(signal LoginSignal) ([]string, error) {
+	var devices []models.KnownDevice
+	if err := DB.Where("username = ?", signal.Username).Order("last_seen_at DESC").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+
+	knownDevice, knownIP := false, false
+	for _, d := range devices {
+		knownDevice = knownDevice || d.Fingerprint == signal.Fingerprint
+		knownIP = knownIP || d.IP == signal.IP
+	}
+	var anomalies []string
+	if !knownDevice {
+		anomalies = append(anomalies, AnomalyNewDevice)
+	}
+	if !knownIP {
+		anomalies = append(anomalies, AnomalyNewIP)
+	}
+
+	// La velocidad se calcula respecto al último login con ubicación conocida
+	if current, ok := locator.Locate(signal.IP); ok {
+		for _, d := range devices {
+			if !d.HasLocation {
+				continue
+			}
+			elapsed := signal.At.Sub(d.LastSeenAt)
+			if elapsed < time.Minute {
+				elapsed = time.Minute
+			}
+			previous := Location{Latitude: d.Latitude, Longitude: d.Longitude}
+			if distanceKm(previous, current)/elapsed.Hours() > MaxTravelSpeed() {
+				anomalies = append(anomalies, AnomalyImpossibleTravel)
+			}
+			break
+		}
+	}
+	return anomalies, nil
+}
+
+// RememberDevice registra o actualiza el dispositivo tras un login aceptado
+func RememberDevice(signal LoginSignal) error {
+	location, hasLocation := locator.Locate(signal.IP)
+	var device models.KnownDevice
+	err := DB.Where("username = ? AND fingerprint = ? AND ip = ?", signal.Username, signal.Fingerprint, signal.IP).
+		First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DB.Create(&models.KnownDevice{
+			Username:    signal.Username,
+			Fingerprint: signal.Fingerprint,
+			IP:          signal.IP,
+			HasLocation: hasLocation,
+			Latitude:    location.Latitude,
+			Longitude:   location.Longitude,
+			FirstSeenAt: signal.At,
+			LastSeenAt:  signal.At,
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+	return DB.Model(&device).Updates(map[string]interface{}{
+		"last_seen_at": signal.At,
+		"has_location": hasLocation,
+		"latitude":     location.Latitude,
+		"longitude":    location.Longitude,
+	}).Error
+}
+
+// CreateLoginChallenge genera un código de un solo uso para verificar un login
+// sospechoso. Devuelve el id del desafío y el código que se envía al usuario.
+func CreateLoginChallenge(signal LoginSignal) (string, string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	challenge := models.LoginChallenge{
+		ID:          hex.EncodeToString(id),
+		Username:    signal.Username,
+		CodeHash:    hashToken(challengeCodeInput(hex.EncodeToString(id), code)),
+		Fingerprint: signal.Fingerprint,
+		IP:          signal.IP,
+		ExpiresAt:   signal.At.Add(loginChallengeTTL),
+	}
+	if err := DB.Create(&challenge).Error; err != nil {
+		return "", "", err
+	}
+	return challenge.ID, code, nil
+}
+
+// challengeCodeInput liga el código a su desafío para que el hash no se
+// pueda reutilizar entre desafíos
+func challengeCodeInput(id, code string) string {
+	return id + ":" + code
+}
+
+// VerifyLoginChallenge comprueba el código y consume el desafío. Cada intento
+// fallido cuenta y el desafío se invalida al alcanzar el máximo.
+func VerifyLoginChallenge(id, code string) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	matched := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND expires_at > ?", id, time.Now()).First(&challenge).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrChallengeInvalid
+			}
+			return err
+		}
+		if challenge.Attempts >= loginChallengeMaxAttempts {
+			return ErrChallengeExhausted
+		}
+		expected := hashToken(challengeCodeInput(id, code))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(challenge.CodeHash)) != 1 {
+			return tx.Model(&challenge).Update("attempts", gorm.Expr("attempts + 1")).Error
+		}
+		matched = true
+		return tx.Delete(&challenge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, ErrChallengeInvalid
+	}
+	return &challenge, nil
+}
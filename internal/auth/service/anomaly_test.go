@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mapLocator ubica cada IP con una tabla fija
+type mapLocator map[string]Location
+
+func (m mapLocator) Locate(ip string) (Location, bool) {
+	location, ok := m[ip]
+	return location, ok
+}
+
+func useLocator(t *testing.T, l Locator) {
+	t.Helper()
+	previous := locator
+	locator = l
+	t.Cleanup(func() { locator = previous })
+}
+
+func TestAssessLogin(t *testing.T) {
+	// Madrid y Buenos Aires están a unos 10.000 km
+	useLocator(t, mapLocator{
+		"1.1.1.1": {Latitude: 40.4, Longitude: -3.7},
+		"1.1.1.2": {Latitude: 40.5, Longitude: -3.6},
+		"2.2.2.2": {Latitude: -34.6, Longitude: -58.4},
+	})
+	start := time.Now().Add(-24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		signal LoginSignal
+		want   []string
+	}{
+		{name: "mismo dispositivo e IP", signal: LoginSignal{Fingerprint: "laptop", IP: "1.1.1.1", At: start.Add(time.Hour)}},
+		{name: "dispositivo nuevo", signal: LoginSignal{Fingerprint: "phone", IP: "1.1.1.1", At: start.Add(time.Hour)},
+			want: []string{AnomalyNewDevice}},
+		{name: "IP nueva cercana", signal: LoginSignal{Fingerprint: "laptop", IP: "1.1.1.2", At: start.Add(time.Hour)},
+			want: []string{AnomalyNewIP}},
+		{name: "viaje imposible", signal: LoginSignal{Fingerprint: "laptop", IP: "2.2.2.2", At: start.Add(time.Hour)},
+			want: []string{AnomalyNewIP, AnomalyImpossibleTravel}},
+		{name: "viaje posible", signal: LoginSignal{Fingerprint: "laptop", IP: "2.2.2.2", At: start.Add(20 * time.Hour)},
+			want: []string{AnomalyNewIP}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			first := LoginSignal{Username: "rick", Fingerprint: "laptop", IP: "1.1.1.1", At: start}
+			// El primer login no tiene referencia
+			if anomalies, err := AssessLogin(first); err != nil || len(anomalies) != 0 {
+				t.Fatalf("primer login: %v, %v", anomalies, err)
+			}
+			if err := RememberDevice(first); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.signal.Username = "rick"
+			got, err := AssessLogin(tt.signal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 0 || len(tt.want) != 0 {
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("anomalías = %v, se esperaba %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAssessLoginIgnoresOtherUsers(t *testing.T) {
+	setupTestDB(t)
+	if err := RememberDevice(LoginSignal{Username: "morty", Fingerprint: "laptop", IP: "1.1.1.1", At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	anomalies, err := AssessLogin(LoginSignal{Username: "rick", Fingerprint: "phone", IP: "2.2.2.2", At: time.Now()})
+	if err != nil || len(anomalies) != 0 {
+		t.Fatalf("los dispositivos de otro usuario no son referencia: %v, %v", anomalies, err)
+	}
+}
+
+func TestVerifyLoginChallenge(t *testing.T) {
+	setupTestDB(t)
+	signal := LoginSignal{Username: "rick", Fingerprint: "phone", IP: "2.2.2.2", At: time.Now()}
+	id, code, err := CreateLoginChallenge(signal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyLoginChallenge(id, "wrong"); !errors.Is(err, ErrChallengeInvalid) {
+		t.Fatalf("código incorrecto: error = %v", err)
+	}
+	challenge, err := VerifyLoginChallenge(id, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Username != "rick" || challenge.Fingerprint != "phone" || challenge.IP != "2.2.2.2" {
+		t.Fatalf("desafío = %+v", challenge)
+	}
+	// El desafío se consume al verificarse
+	if _, err := VerifyLoginChallenge(id, code); !errors.Is(err, ErrChallengeInvalid) {
+		t.Fatalf("segundo uso: error = %v", err)
+	}
+}
+
+func TestVerifyLoginChallengeAttemptCap(t *testing.T) {
+	setupTestDB(t)
+	id, code, err := CreateLoginChallenge(LoginSignal{Username: "rick", At: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < loginChallengeMaxAttempts; i++ {
+		if _, err := VerifyLoginChallenge(id, "wrong"); !errors.Is(err, ErrChallengeInvalid) {
+			t.Fatalf("intento %d: error = %v", i, err)
+		}
+	}
+	// Agotados los intentos, ni siquiera el código correcto se acepta
+	if _, err := VerifyLoginChallenge(id, code); !errors.Is(err, ErrChallengeExhausted) {
+		t.Fatalf("tras agotar los intentos: error = %v", err)
+	}
+}
+
+func TestVerifyLoginChallengeExpired(t *testing.T) {
+	setupTestDB(t)
+	id, code, err := CreateLoginChallenge(LoginSignal{Username: "rick", At: time.Now().Add(-loginChallengeTTL - time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyLoginChallenge(id, code); !errors.Is(err, ErrChallengeInvalid) {
+		t.Fatalf("desafío expirado: error = %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"log"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+)
+
+// Acciones registradas en la auditoría
+const (
+	AuditRegister             = "register"
+	AuditLogin                = "login"
+	AuditLoginFailed          = "login_failed"
+	AuditDeactivate           = "deactivate"
+	AuditReactivate           = "reactivate"
+	AuditInviteCreated        = "invite_created"
+	AuditDeviceApproved       = "device_approved"
+	AuditImpersonate          = "impersonate"
+	AuditImpersonatedRequest  = "impersonated_request"
+	AuditPasswordChanged      = "password_changed"
+	AuditOrgMemberInvited     = "org_member_invited"
+	AuditOrgMemberAdded       = "org_member_added"
+	AuditOrgMemberRemoved     = "org_member_removed"
+	AuditOrgMemberLeft        = "org_member_left"
+	AuditTokenBindingMismatch = "token_binding_mismatch"
+	AuditLoginAnomaly         = "login_anomaly"
+	AuditStepUpVerified       = "step_up_verified"
+	AuditStepUpFailed         = "step_up_failed"
+	AuditLogout               = "logout"
+	AuditTokenRefreshed       = "token_refreshed"
+)
+
+// RecordAudit guarda un evento de auditoría. Los errores solo se registran en
+// el log para no interrumpir la petición que los origina.
+func RecordAudit(username, action, detail, ip string) {
+	event := models.AuditEvent{Username: username, Action: action, Detail: detail, IP: ip}
+	if err := DB.Create(&event).Error; err != nil {
+		log.Printf("[AUTH] Error guardando evento de auditoría %s: %v", action, err)
+	}
+}
+
+// ListAuditEvents devuelve los eventos más recientes, opcionalmente filtrados por usuario
+func ListAuditEvents(username string, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	query := DB.Order("created_at desc").Limit(limit)
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"bufio"
+	"container/heap"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrBreachedPassword indica que la contraseña aparece en filtraciones conocidas
+var ErrBreachedPassword = errors.New("la contraseña aparece en filtraciones conocidas")
+
+// La base de contraseñas filtradas sigue el formato de rangos de k-anonimato
+// de Have I Been Pwned: un archivo por prefijo de 5 caracteres del SHA-1, con
+// líneas "SUFIJO:APARICIONES". Cada comprobación lee un solo archivo pequeño
+// y no se hace ninguna llamada de red.
+const breachedPrefixLen = 5
+
+// commonPasswordsFile es la lista de contraseñas más frecuentes que se
+// incluye en el binario y se rechaza siempre, haya o no una base configurada
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = func() map[string]bool {
+	set := map[string]bool{}
+	for _, line := range strings.Split(commonPasswordsFile, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			set[line] = true
+		}
+	}
+	return set
+}()
+
+// isCommonPassword indica si la contraseña, o su versión en minúsculas, está
+// en la lista incluida
+func isCommonPassword(password string) bool {
+	return commonPasswords[password] || commonPasswords[strings.ToLower(password)]
+}
+
+// BreachedPasswordsDir es el directorio de la base (BREACHED_PASSWORDS_DIR).
+// Vacío deja solo la lista incluida en el binario.
+func BreachedPasswordsDir() string {
+	return os.Getenv("BREACHED_PASSWORDS_DIR")
+}
+
+// breachedMinCount es el número mínimo de apariciones para rechazar una
+// contraseña (BREACHED_PASSWORDS_MIN_COUNT, por defecto 1)
+func breachedMinCount() int {
+	n, err := strconv.Atoi(os.Getenv("BREACHED_PASSWORDS_MIN_COUNT"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// BreachedPasswordCount devuelve cuántas veces aparece la contraseña en la base
+func BreachedPasswordCount(dir, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	entries, err := readBreachedRange(dir, hash[:breachedPrefixLen])
+	if err != nil {
+		return 0, err
+	}
+	return entries[hash[breachedPrefixLen:]], nil
+}
+
+// CheckPassword devuelve ErrBreachedPassword si la contraseña está en la lista
+// incluida o en la base
+func CheckPassword(password string) error {
+	if isCommonPassword(password) {
+		return ErrBreachedPassword
+	}
+	dir := BreachedPasswordsDir()
+	if dir == "" {
+		return nil
+	}
+	count, err := BreachedPasswordCount(dir, password)
+	if err != nil {
+		return err
+	}
+	if count >= breachedMinCount() {
+		return ErrBreachedPassword
+	}
+	return nil
+}
+
+func readBreachedRange(dir, prefix string) (map[string]int, error) {
+	entries := map[string]int{}
+	f, err := os.Open(filepath.Join(dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, count, ok := parseBreachedLine(scanner.Text())
+		if ok {
+			entries[suffix] = count
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseBreachedLine interpreta "HASH:APARICIONES" o solo "HASH"
+func parseBreachedLine(line string) (string, int, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", 0, false
+	}
+	hash, countText, hasCount := strings.Cut(line, ":")
+	count := 1
+	if hasCount {
+		n, err := strconv.Atoi(strings.TrimSpace(countText))
+		if err != nil {
+			return "", 0, false
+		}
+		count = n
+	}
+	return strings.ToUpper(strings.TrimSpace(hash)), count, true
+}
+
+// breachedRunSize es el número de hashes que se ordenan en memoria antes de
+// volcarlos a un archivo temporal, de modo que la importación usa memoria
+// acotada aunque el volcado tenga cientos de millones de líneas
+var breachedRunSize = 1 << 19
+
+type breachedEntry struct {
+	hash  string
+	count int
+}
+
+// ImportBreachedPasswords incorpora a la base los hashes SHA-1 completos de
+// source ("HASH:APARICIONES", como el volcado de Have I Been Pwned) o, con
+// plain, contraseñas en claro, una por línea. La entrada se ordena por partes
+// en archivos temporales y se mezcla después, rango a rango. Los rangos
+// existentes se combinan y cada archivo se reemplaza de forma atómica, así
+// que la base se puede actualizar con el servicio en marcha. Devuelve los
+// hashes importados.
+func ImportBreachedPasswords(dir string, source io.Reader, plain bool) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	tmpDir, err := os.MkdirTemp(dir, ".import")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var runs []string
+	chunk := make([]breachedEntry, 0, breachedRunSize)
+	imported := 0
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := breachedEntry{count: 1}
+		if plain {
+			if scanner.Text() == "" {
+				continue
+			}
+			sum := sha1.Sum([]byte(scanner.Text()))
+			entry.hash = strings.ToUpper(hex.EncodeToString(sum[:]))
+		} else {
+			var ok bool
+			if entry.hash, entry.count, ok = parseBreachedLine(scanner.Text()); !ok {
+				continue
+			}
+			if len(entry.hash) != 40 {
+				return imported, fmt.Errorf("hash SHA-1 inválido: %s", entry.hash)
+			}
+			if _, err := hex.DecodeString(entry.hash); err != nil {
+				return imported, fmt.Errorf("hash SHA-1 inválido: %s", entry.hash)
+			}
+		}
+		chunk = append(chunk, entry)
+		imported++
+		if len(chunk) == breachedRunSize {
+			run, err := writeBreachedRun(tmpDir, chunk)
+			if err != nil {
+				return imported, err
+			}
+			runs = append(runs, run)
+			chunk = chunk[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, err
+	}
+	if len(chunk) > 0 {
+		run, err := writeBreachedRun(tmpDir, chunk)
+		if err != nil {
+			return imported, err
+		}
+		runs = append(runs, run)
+	}
+	return imported, mergeBreachedRuns(dir, runs)
+}
+
+// writeBreachedRun ordena los hashes y los escribe en un archivo temporal
+func writeBreachedRun(tmpDir string, entries []breachedEntry) (string, error) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+	f, err := os.CreateTemp(tmpDir, "run")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	writer := bufio.NewWriter(f)
+	for _, e := range entries {
+		fmt.Fprintf(writer, "%s:%d\n", e.hash, e.count)
+	}
+	if err := writer.Flush(); err != nil {
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// breachedRun recorre un archivo temporal ordenado
+type breachedRun struct {
+	scanner *bufio.Scanner
+	breachedEntry
+}
+
+func (r *breachedRun) next() bool {
+	for r.scanner.Scan() {
+		var ok bool
+		if r.hash, r.count, ok = parseBreachedLine(r.scanner.Text()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// breachedHeap ordena los archivos temporales por su hash actual
+type breachedHeap []*breachedRun
+
+func (h breachedHeap) Len() int            { return len(h) }
+func (h breachedHeap) Less(i, j int) bool  { return h[i].hash < h[j].hash }
+func (h breachedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *breachedHeap) Push(x interface{}) { *h = append(*h, x.(*breachedRun)) }
+func (h *breachedHeap) Pop() interface{} {
+	old := *h
+	run := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return run
+}
+
+// mergeBreachedRuns mezcla los archivos temporales en orden y escribe cada
+// rango en cuanto se completa: en memoria solo hay un rango a la vez
+func mergeBreachedRuns(dir string, paths []string) error {
+	var runs []*breachedRun
+	h := &breachedHeap{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		run := &breachedRun{scanner: bufio.NewScanner(f)}
+		runs = append(runs, run)
+		if run.next() {
+			heap.Push(h, run)
+		}
+	}
+
+	prefix := ""
+	entries := map[string]int{}
+	flush := func() error {
+		if prefix == "" {
+			return nil
+		}
+		existing, err := readBreachedRange(dir, prefix)
+		if err != nil {
+			return err
+		}
+		for suffix, count := range entries {
+			if count > existing[suffix] {
+				existing[suffix] = count
+			}
+		}
+		return writeBreachedRange(dir, prefix, existing)
+	}
+	for h.Len() > 0 {
+		run := (*h)[0]
+		if current := run.hash[:breachedPrefixLen]; current != prefix {
+			if err := flush(); err != nil {
+				return err
+			}
+			prefix, entries = current, map[string]int{}
+		}
+		suffix := run.hash[breachedPrefixLen:]
+		if run.count > entries[suffix] {
+			entries[suffix] = run.count
+		}
+		if run.next() {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if err := run.scanner.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBreachedRange(dir, prefix string, entries map[string]int) error {
+	suffixes := make([]string, 0, len(entries))
+	for suffix := range entries {
+		suffixes = append(suffixes, suffix)
+	}
+	sort.Strings(suffixes)
+
+	tmp, err := os.CreateTemp(dir, prefix+".tmp*")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, suffix := range suffixes {
+		fmt.Fprintf(writer, "%s:%d\n", suffix, entries[suffix])
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, prefix))
+}
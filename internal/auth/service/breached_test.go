@@ -0,0 +1,123 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestCheckPasswordBundledList(t *testing.T) {
+	// Sin base configurada se sigue usando la lista incluida
+	t.Setenv("BREACHED_PASSWORDS_DIR", "")
+	tests := []struct {
+		password string
+		breached bool
+	}{
+		{"123456", true},
+		{"password", true},
+		{"QWERTY", true},
+		{"admin123", true},
+		{"wubba-lubba-dub-dub-42", false},
+	}
+	for _, tt := range tests {
+		err := CheckPassword(tt.password)
+		if errors.Is(err, ErrBreachedPassword) != tt.breached {
+			t.Errorf("CheckPassword(%q) = %v", tt.password, err)
+		}
+	}
+}
+
+func TestImportBreachedPasswords(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BREACHED_PASSWORDS_DIR", dir)
+	// Varios archivos temporales para ejercitar la mezcla
+	previous := breachedRunSize
+	breachedRunSize = 3
+	t.Cleanup(func() { breachedRunSize = previous })
+
+	if _, err := ImportBreachedPasswords(dir, strings.NewReader("squanchy\nschwifty\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	var dump strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&dump, "%s:%d\n", sha1Hex(fmt.Sprintf("plumbus-%d", i)), i+1)
+	}
+	// Un hash repetido conserva el mayor número de apariciones
+	fmt.Fprintf(&dump, "%s:7\n%s:2\n", sha1Hex("squanchy"), sha1Hex("plumbus-0"))
+	imported, err := ImportBreachedPasswords(dir, strings.NewReader(dump.String()), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 12 {
+		t.Fatalf("importados = %d", imported)
+	}
+
+	tests := []struct {
+		password string
+		count    int
+	}{
+		{"squanchy", 7},
+		{"schwifty", 1},
+		{"plumbus-0", 2},
+		{"plumbus-9", 10},
+		{"meeseeks", 0},
+	}
+	for _, tt := range tests {
+		count, err := BreachedPasswordCount(dir, tt.password)
+		if err != nil || count != tt.count {
+			t.Errorf("%s: %d apariciones (%v), se esperaban %d", tt.password, count, err, tt.count)
+		}
+	}
+
+	t.Setenv("BREACHED_PASSWORDS_MIN_COUNT", "5")
+	if err := CheckPassword("squanchy"); !errors.Is(err, ErrBreachedPassword) {
+		t.Errorf("squanchy: %v", err)
+	}
+	if err := CheckPassword("schwifty"); err != nil {
+		t.Errorf("schwifty por debajo del mínimo: %v", err)
+	}
+
+	// No quedan archivos temporales junto a los rangos
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		if len(f.Name()) != breachedPrefixLen {
+			t.Errorf("archivo inesperado en la base: %s", f.Name())
+		}
+	}
+}
+
+func TestImportBreachedPasswordsInvalidHash(t *testing.T) {
+	if _, err := ImportBreachedPasswords(t.TempDir(), strings.NewReader("NO-ES-UN-HASH:3\n"), false); err == nil {
+		t.Fatal("se esperaba un error")
+	}
+}
+
+func TestCheckAdminPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		weak     bool
+	}{
+		{"admin123", true},
+		{"corto", true},
+		{"qwerty123456", true},
+		{"administrator", true},
+		{"Administrator", true},
+		{"admin-rick-c137", true},
+		{"portal-gun-c137-fluid", false},
+	}
+	for _, tt := range tests {
+		err := checkAdminPassword("admin-rick-c137", tt.password)
+		if errors.Is(err, ErrWeakAdminPassword) != tt.weak {
+			t.Errorf("checkAdminPassword(%q) = %v", tt.password, err)
+		}
+	}
+}
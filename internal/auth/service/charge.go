@@ -0,0 +1,40 @@
+package service
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Charge es lo que se cobró por una petición
+type Charge struct {
+	Usage             *UsageStatus
+	OrgQuotaRemaining int
+	DebitID           string
+	Credits           int
+}
+
+// ChargeRequest cobra una petición: la cuenta en las ventanas de uso del
+// usuario, la descuenta de la cuota de su organización si orgID no es 0 y
+// registra el cargo en el libro. Todo ocurre en una sola transacción, así que
+// si algún paso la rechaza (ErrRateLimited, ErrQuotaExceeded o
+// ErrInsufficientCredits) no queda nada cobrado. Con ErrRateLimited se devuelve también el estado de las ventanas.
+func ChargeRequest(username, plan string, orgID int, description string, now time.Time) (*Charge, error) {
+	charge := &Charge{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		charge.Usage, err = consumeUsage(tx, username, plan, now)
+		if err != nil {
+			return err
+		}
+		if orgID != 0 {
+			charge.OrgQuotaRemaining, err = consumeOrgQuota(tx, orgID)
+			if err != nil {
+				return err
+			}
+		}
+		charge.DebitID, charge.Credits, err = debit(tx, username, 1, description)
+		return err
+	})
+	return charge, err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+)
+
+func TestChargeRequestRollsBack(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("RATE_LIMITS_FREE", "2,0,0")
+	createUsers(t, "rick")
+	org, err := CreateOrganization("citadel", "free", "rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TopUp("rick", 10, "test"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		charge, err := ChargeRequest("rick", "free", org.ID, "GET /api/v1/characters", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if charge.DebitID == "" || charge.Usage.Limiting.Remaining != 1-i {
+			t.Fatalf("cobro %d = %+v", i, charge)
+		}
+	}
+
+	// La tercera petición supera la ventana por minuto: ni cuota ni cargo
+	charge, err := ChargeRequest("rick", "free", org.ID, "", now)
+	if !errors.Is(err, ErrRateLimited) || charge.Usage == nil {
+		t.Fatalf("ChargeRequest = %+v, %v", charge, err)
+	}
+	assertOrgUsed(t, org.ID, 2)
+	assertDebits(t, "rick", 2)
+
+	// Con la cuota de la organización agotada tampoco se cuenta la ventana
+	DB.Model(&models.Organization{}).Where("id = ?", org.ID).Update("used", PlanQuota("free"))
+	later := now.Add(time.Minute)
+	if _, err := ChargeRequest("rick", "free", org.ID, "", later); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("se esperaba ErrQuotaExceeded: %v", err)
+	}
+	assertDebits(t, "rick", 2)
+	usage, err := GetUsage("rick", "free", later)
+	if err != nil || usage[0].Remaining != 2 {
+		t.Fatalf("uso tras el rechazo = %+v, %v", usage, err)
+	}
+}
+
+func TestChargeRequestInsufficientCredits(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "morty")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := ChargeRequest("morty", "free", 0, "", now); !errors.Is(err, ErrInsufficientCredits) {
+		t.Fatalf("se esperaba ErrInsufficientCredits: %v", err)
+	}
+	// El rechazo por créditos tampoco cuenta en las ventanas
+	usage, err := GetUsage("morty", "free", now)
+	if err != nil || usage[0].Remaining != usage[0].Limit {
+		t.Fatalf("uso tras el rechazo = %+v, %v", usage, err)
+	}
+}
+
+func assertOrgUsed(t *testing.T, orgID, want int) {
+	t.Helper()
+	org, err := GetOrganization(orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.Used != want {
+		t.Fatalf("cuota usada = %d, se esperaba %d", org.Used, want)
+	}
+}
+
+func assertDebits(t *testing.T, username string, want int64) {
+	t.Helper()
+	var count int64
+	DB.Model(&models.LedgerEntry{}).Where("account = ? AND kind = ?", models.UserAccount(username), models.LedgerDebit).Count(&count)
+	if count != want {
+		t.Fatalf("cargos = %d, se esperaban %d", count, want)
+	}
+}
@@ -1,21 +1,57 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
 	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+var ErrSourceMismatch = errors.New("el usuario pertenece a otro backend de autenticación")
+
 func InitDB() error {
-	db, err := gorm.Open(sqlite.Open("/app/data/users.db"), &gorm.Config{})
+	return InitDBAt("/app/data/users.db")
+}
+
+// InitDBAt abre la base de datos del fichero path, por ejemplo una temporal en
+// los tests de los handlers
+func InitDBAt(path string) error {
+	db, err := openDB(path)
 	if err != nil {
 		return err
 	}
-	db.AutoMigrate(&models.User{})
 	DB = db
-	return nil
+	return EnsureDefaultGroups()
+}
+
+// openDB abre la base de datos SQLite del fichero path y aplica las migraciones
+func openDB(path string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := instrumentDB(db); err != nil {
+		return nil, err
+	}
+	db.AutoMigrate(&models.User{}, &models.Invite{}, &models.Token{}, &models.AuditEvent{}, &models.DeviceAuthorization{},
+		&models.Organization{}, &models.OrgMembership{}, &models.LedgerEntry{},
+		&models.ServiceAccount{}, &models.ServiceAccountSecret{}, &models.Group{},
+		&models.KnownDevice{}, &models.LoginChallenge{}, &models.UsageWindow{}, &models.OrgInvitation{})
+	// Los tokens ya no tienen usos: la medición se hace con ventanas de uso
+	if db.Migrator().HasColumn(&models.Token{}, "uses") {
+		if err := db.Migrator().DropColumn(&models.Token{}, "uses"); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
 }
 
 func CreateUser(username, password string) error {
@@ -28,3 +64,90 @@ func GetUserByUsername(username string) (*models.User, error) {
 	err := DB.Where("username = ?", username).First(&user).Error
 	return &user, err
 }
+
+// minAdminPasswordLength es la longitud mínima de ADMIN_PASSWORD
+const minAdminPasswordLength = 12
+
+// ErrWeakAdminPassword indica que ADMIN_PASSWORD es demasiado débil
+var ErrWeakAdminPassword = errors.New("ADMIN_PASSWORD es demasiado débil: use al menos 12 caracteres y no un valor por defecto")
+
+// checkAdminPassword rechaza las contraseñas de administrador cortas, de la
+// lista de contraseñas frecuentes (que incluye los valores de ejemplo como
+// admin123) o iguales al nombre de usuario
+func checkAdminPassword(username, password string) error {
+	if len(password) < minAdminPasswordLength || isCommonPassword(password) ||
+		strings.EqualFold(password, username) {
+		return ErrWeakAdminPassword
+	}
+	return nil
+}
+
+// EnsureAdminUser crea el usuario administrador inicial si no existe.
+// Si ya existe, se asegura de que tenga el rol de administrador. Una
+// contraseña débil se rechaza siempre; sin contraseña, el administrador se
+// crea con una aleatoria que se devuelve para mostrarla una sola vez.
+func EnsureAdminUser(username, password string) (generated string, err error) {
+	if password != "" {
+		if err := checkAdminPassword(username, password); err != nil {
+			return "", err
+		}
+	}
+	user, err := GetUserByUsername(username)
+	if err == nil {
+		return "", DB.Model(user).Update("role", models.RoleAdmin).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if password == "" {
+		random := make([]byte, 18)
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		password = base64.RawURLEncoding.EncodeToString(random)
+		generated = password
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return generated, DB.Create(&models.User{Username: username, Password: string(hash), Role: models.RoleAdmin}).Error
+}
+
+// ProvisionUser devuelve el usuario local asociado a una identidad externa y lo
+// crea la primera vez que inicia sesión. La contraseña local es aleatoria e
+// inutilizable: la autenticación sigue delegada en el backend de origen.
+func ProvisionUser(username, source string) (*models.User, error) {
+	user, err := GetUserByUsername(username)
+	if err == nil {
+		// Un backend externo no puede suplantar a un usuario de otro origen
+		if user.Source != source {
+			return nil, ErrSourceMismatch
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(random)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user = &models.User{Username: username, Password: string(hash), Role: models.RoleUser, Plan: "free", Source: source}
+	if err := DB.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdatePassword reemplaza el hash de la contraseña y revoca los tokens del usuario
+func UpdatePassword(username, hash string) error {
+	if err := DB.Model(&models.User{}).Where("username = ?", username).Update("password", hash).Error; err != nil {
+		return err
+	}
+	return RevokeUserTokens(username)
+}
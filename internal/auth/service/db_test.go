@@ -0,0 +1,28 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB abre una base de datos vacía en un directorio temporal y la
+// deja como DB mientras dura el test
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := openDB(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	// Los "record not found" esperados no ensucian la salida de los tests
+	DB = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		DB = previous
+	})
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 // segundos
+	// Alfabeto sin vocales ni caracteres ambiguos (RFC 8628, sección 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// Errores del endpoint de token, con los nombres de error de RFC 8628
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+)
+
+func generateUserCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, len(b))
+	for i, v := range b {
+		code[i] = userCodeAlphabet[int(v)%len(userCodeAlphabet)]
+	}
+	return string(code[:4]) + "-" + string(code[4:]), nil
+}
+
+// NormalizeUserCode acepta el código en minúsculas y con o sin guion o espacios
+func NormalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 8 {
+		return code
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+// CreateDeviceAuthorization inicia el flujo y devuelve el device code en claro
+func CreateDeviceAuthorization(clientID string) (string, *models.DeviceAuthorization, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	deviceCode := hex.EncodeToString(raw)
+	userCode, err := generateUserCode()
+	if err != nil {
+		return "", nil, err
+	}
+	auth := models.DeviceAuthorization{
+		DeviceCodeHash: hashToken(deviceCode),
+		UserCode:       userCode,
+		ClientID:       clientID,
+		Status:         models.DeviceStatusPending,
+		Interval:       devicePollInterval,
+		ExpiresAt:      time.Now().Add(deviceCodeTTL),
+	}
+	if err := DB.Create(&auth).Error; err != nil {
+		return "", nil, err
+	}
+	return deviceCode, &auth, nil
+}
+
+// GetPendingDeviceAuthorization busca una solicitud pendiente y vigente por su user code
+func GetPendingDeviceAuthorization(userCode string) (*models.DeviceAuthorization, error) {
+	var auth models.DeviceAuthorization
+	err := DB.Where("user_code = ? AND status = ? AND expires_at > ?",
+		NormalizeUserCode(userCode), models.DeviceStatusPending, time.Now()).First(&auth).Error
+	if err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+// ResolveDeviceAuthorization aprueba o rechaza una solicitud pendiente
+func ResolveDeviceAuthorization(userCode, username string, approve bool) error {
+	status := models.DeviceStatusDenied
+	if approve {
+		status = models.DeviceStatusApproved
+	}
+	result := DB.Model(&models.DeviceAuthorization{}).
+		Where("user_code = ? AND status = ? AND expires_at > ?",
+			NormalizeUserCode(userCode), models.DeviceStatusPending, time.Now()).
+		Updates(map[string]interface{}{"status": status, "username": username})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// PollDeviceAuthorization atiende un sondeo del endpoint de token. Si la
+// solicitud fue aprobada la marca como consumida y la devuelve.
+func PollDeviceAuthorization(deviceCode, clientID string) (*models.DeviceAuthorization, error) {
+	var auth models.DeviceAuthorization
+	// Los errores del protocolo se devuelven aparte para que la transacción
+	// confirme igualmente el registro del sondeo
+	var pollErr error
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("device_code_hash = ? AND client_id = ?", hashToken(deviceCode), clientID).First(&auth).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			pollErr = ErrInvalidGrant
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if now.After(auth.ExpiresAt) {
+			pollErr = ErrExpiredToken
+			return nil
+		}
+
+		// Un cliente que sondea más rápido que el intervalo recibe slow_down
+		// y su intervalo aumenta 5 segundos (RFC 8628, sección 3.5)
+		tooFast := auth.LastPolledAt != nil && now.Sub(*auth.LastPolledAt) < time.Duration(auth.Interval)*time.Second
+		updates := map[string]interface{}{"last_polled_at": now}
+		if tooFast {
+			updates["interval"] = auth.Interval + 5
+		}
+		if err := tx.Model(&auth).Updates(updates).Error; err != nil {
+			return err
+		}
+		if tooFast {
+			pollErr = ErrSlowDown
+			return nil
+		}
+
+		switch auth.Status {
+		case models.DeviceStatusPending:
+			pollErr = ErrAuthorizationPending
+		case models.DeviceStatusDenied:
+			pollErr = ErrAccessDenied
+		case models.DeviceStatusApproved:
+			return tx.Model(&auth).Update("status", models.DeviceStatusConsumed).Error
+		default:
+			pollErr = ErrInvalidGrant
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pollErr != nil {
+		return nil, pollErr
+	}
+	return &auth, nil
+}
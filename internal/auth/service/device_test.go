@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+)
+
+// waitInterval simula que el dispositivo respetó el intervalo de sondeo
+func waitInterval(t *testing.T, auth *models.DeviceAuthorization) {
+	t.Helper()
+	if err := DB.Model(&models.DeviceAuthorization{}).Where("id = ?", auth.ID).
+		Update("last_polled_at", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPollDeviceAuthorization(t *testing.T) {
+	setupTestDB(t)
+	deviceCode, auth, err := CreateDeviceAuthorization("cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PollDeviceAuthorization(deviceCode, "cli"); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("sin aprobar: error = %v, se esperaba authorization_pending", err)
+	}
+	if _, err := PollDeviceAuthorization(deviceCode, "cli"); !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("sondeo inmediato: error = %v, se esperaba slow_down", err)
+	}
+	var stored models.DeviceAuthorization
+	DB.First(&stored, auth.ID)
+	if stored.Interval != devicePollInterval+5 {
+		t.Fatalf("intervalo tras slow_down = %d", stored.Interval)
+	}
+
+	waitInterval(t, auth)
+	if _, err := PollDeviceAuthorization(deviceCode, "otro-cli"); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("otro client_id: error = %v, se esperaba invalid_grant", err)
+	}
+	if err := ResolveDeviceAuthorization(auth.UserCode, "rick", true); err != nil {
+		t.Fatal(err)
+	}
+	got, err := PollDeviceAuthorization(deviceCode, "cli")
+	if err != nil {
+		t.Fatalf("aprobada: error = %v", err)
+	}
+	if got.Username != "rick" {
+		t.Fatalf("usuario = %q, se esperaba rick", got.Username)
+	}
+
+	// El device code solo se canjea una vez
+	waitInterval(t, auth)
+	if _, err := PollDeviceAuthorization(deviceCode, "cli"); !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("segundo canje: error = %v, se esperaba invalid_grant", err)
+	}
+	if err := ResolveDeviceAuthorization(auth.UserCode, "morty", true); err == nil {
+		t.Fatal("se pudo aprobar de nuevo una solicitud ya canjeada")
+	}
+}
+
+func TestPollDeviceAuthorizationDenied(t *testing.T) {
+	setupTestDB(t)
+	deviceCode, auth, err := CreateDeviceAuthorization("cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// El código se acepta en minúsculas y sin guion
+	lower := strings.ToLower(auth.UserCode[:4] + auth.UserCode[5:])
+	if err := ResolveDeviceAuthorization(lower, "rick", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PollDeviceAuthorization(deviceCode, "cli"); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("rechazada: error = %v, se esperaba access_denied", err)
+	}
+}
+
+func TestPollDeviceAuthorizationExpired(t *testing.T) {
+	setupTestDB(t)
+	deviceCode, auth, err := CreateDeviceAuthorization("cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Model(auth).Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PollDeviceAuthorization(deviceCode, "cli"); !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("expirada: error = %v, se esperaba expired_token", err)
+	}
+	if err := ResolveDeviceAuthorization(auth.UserCode, "rick", true); err == nil {
+		t.Fatal("se pudo aprobar una solicitud expirada")
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Location es una posición geográfica aproximada
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Locator resuelve la ubicación aproximada de una IP
+type Locator interface {
+	Locate(ip string) (Location, bool)
+}
+
+type noLocator struct{}
+
+func (noLocator) Locate(string) (Location, bool) { return Location{}, false }
+
+type cidrLocation struct {
+	network  *net.IPNet
+	location Location
+}
+
+// CIDRLocator resuelve IPs con una tabla de rangos cargada desde CSV
+type CIDRLocator []cidrLocation
+
+func (c CIDRLocator) Locate(ip string) (Location, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}, false
+	}
+	for _, entry := range c {
+		if entry.network.Contains(parsed) {
+			return entry.location, true
+		}
+	}
+	return Location{}, false
+}
+
+var locator Locator = noLocator{}
+
+// LoadGeoIP carga un CSV con líneas "cidr,latitud,longitud". Sin tabla no se
+// detectan viajes imposibles, solo dispositivos e IPs nuevos.
+func LoadGeoIP(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	table := make(CIDRLocator, 0, len(records))
+	for i, record := range records {
+		if len(record) != 3 {
+			return fmt.Errorf("línea %d: se esperaban 3 columnas", i+1)
+		}
+		_, network, err := net.ParseCIDR(strings.TrimSpace(record[0]))
+		if err != nil {
+			return fmt.Errorf("línea %d: %v", i+1, err)
+		}
+		lat, err1 := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		lon, err2 := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("línea %d: coordenadas inválidas", i+1)
+		}
+		table = append(table, cidrLocation{network: network, location: Location{Latitude: lat, Longitude: lon}})
+	}
+	locator = table
+	return nil
+}
+
+// distanceKm calcula la distancia de gran círculo con la fórmula de haversine
+func distanceKm(a, b Location) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLon := toRad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInviteInvalid = errors.New("código de invitación inválido, expirado o agotado")
+	ErrUserExists    = errors.New("usuario ya existe")
+)
+
+// generateInviteCode genera un código aleatorio legible para compartir
+func generateInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+// CreateInvite crea un nuevo código de invitación
+func CreateInvite(createdBy string, maxUses int, ttl time.Duration, role, plan string) (*models.Invite, error) {
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	invite := models.Invite{
+		Code:      code,
+		MaxUses:   maxUses,
+		Role:      role,
+		Plan:      plan,
+		CreatedBy: createdBy,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := DB.Create(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// ListInvites devuelve todas las invitaciones, las más recientes primero
+func ListInvites() ([]models.Invite, error) {
+	var invites []models.Invite
+	err := DB.Order("created_at desc").Find(&invites).Error
+	return invites, err
+}
+
+// RevokeInvite elimina un código de invitación
+func RevokeInvite(code string) error {
+	result := DB.Where("code = ?", code).Delete(&models.Invite{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CreateUserWithInvite canjea un código de invitación y crea el usuario con el
+// rol y plan por defecto de la invitación, todo dentro de una transacción.
+// Devuelve también la invitación canjeada.
+func CreateUserWithInvite(username, password, code string) (*models.User, *models.Invite, error) {
+	var user models.User
+	var invite models.Invite
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Consumir un uso solo si la invitación sigue vigente
+		result := tx.Model(&models.Invite{}).
+			Where("code = ? AND uses < max_uses AND expires_at > ?", code, time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInviteInvalid
+		}
+
+		if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+			return err
+		}
+
+		user = models.User{Username: username, Password: password, Role: invite.Role, Plan: invite.Plan}
+		if err := tx.Create(&user).Error; err != nil {
+			return ErrUserExists
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, &invite, nil
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDebitNotFound       = errors.New("cargo no encontrado")
+	ErrAlreadyRefunded     = errors.New("el cargo ya fue reembolsado")
+	ErrInsufficientCredits = errors.New("créditos insuficientes")
+)
+
+// Statement es el extracto de una cuenta en un rango de fechas
+type Statement struct {
+	Account        string               `json:"account"`
+	From           *time.Time           `json:"from,omitempty"`
+	To             *time.Time           `json:"to,omitempty"`
+	OpeningBalance int                  `json:"opening_balance"`
+	ClosingBalance int                  `json:"closing_balance"`
+	Totals         map[string]int       `json:"totals"`
+	Entries        []models.LedgerEntry `json:"entries"`
+}
+
+func newTxID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// postTransfer registra una transacción de partida doble: mueve amount
+// créditos de la cuenta from a la cuenta to
+func postTransfer(tx *gorm.DB, from, to string, amount int, kind, reference, description string) (string, error) {
+	txID, err := newTxID()
+	if err != nil {
+		return "", err
+	}
+	entries := []models.LedgerEntry{
+		{TxID: txID, Account: from, Amount: -amount, Kind: kind, Reference: reference, Description: description},
+		{TxID: txID, Account: to, Amount: amount, Kind: kind, Reference: reference, Description: description},
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return "", err
+	}
+	return txID, nil
+}
+
+func balance(tx *gorm.DB, account string) (int, error) {
+	var total int
+	err := tx.Model(&models.LedgerEntry{}).Where("account = ?", account).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
+
+// deleteLedgerEntries elimina las transacciones completas en las que participan
+// las cuentas de los usuarios, para que el libro siga cuadrando y un usuario
+// que se registre con el mismo nombre empiece sin saldo heredado
+func deleteLedgerEntries(tx *gorm.DB, usernames []string) error {
+	accounts := make([]string, len(usernames))
+	for i, username := range usernames {
+		accounts[i] = models.UserAccount(username)
+	}
+	txIDs := tx.Model(&models.LedgerEntry{}).Select("tx_id").Where("account IN ?", accounts)
+	return tx.Where("tx_id IN (?)", txIDs).Delete(&models.LedgerEntry{}).Error
+}
+
+// Balance devuelve el saldo de créditos del usuario
+func Balance(username string) (int, error) {
+	return balance(DB, models.UserAccount(username))
+}
+
+// EnsureSignupGrant otorga los créditos iniciales del plan a los usuarios que
+// aún no tienen movimientos en el libro
+func EnsureSignupGrant(user *models.User) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		account := models.UserAccount(user.Username)
+		if err := tx.Model(&models.LedgerEntry{}).Where("account = ?", account).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		_, err := postTransfer(tx, models.AccountGrants, account, PlanSignupCredits(user.Plan), models.LedgerGrant, "", "Créditos iniciales del plan "+user.Plan)
+		return err
+	})
+}
+
+// TopUp agrega créditos a la cuenta del usuario
+func TopUp(username string, amount int, description string) (string, error) {
+	return postTransfer(DB, models.AccountGrants, models.UserAccount(username), amount, models.LedgerTopUp, "", description)
+}
+
+// Debit cobra una petición al usuario y devuelve el id de la transacción, para
+// un posible reembolso, y el saldo restante. Si el saldo no alcanza no se
+// cobra nada y devuelve ErrInsufficientCredits.
+func Debit(username string, amount int, description string) (string, int, error) {
+	var txID string
+	var remaining int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		txID, remaining, err = debit(tx, username, amount, description)
+		return err
+	})
+	return txID, remaining, err
+}
+
+// debit registra el cargo dentro de la transacción tx. El saldo se lee en la
+// misma transacción, así que dos cargos simultáneos no lo pueden dejar en negativo.
+func debit(tx *gorm.DB, username string, amount int, description string) (string, int, error) {
+	account := models.UserAccount(username)
+	current, err := balance(tx, account)
+	if err != nil {
+		return "", 0, err
+	}
+	if current < amount {
+		return "", current, ErrInsufficientCredits
+	}
+	txID, err := postTransfer(tx, account, models.AccountUsage, amount, models.LedgerDebit, "", description)
+	if err != nil {
+		return "", 0, err
+	}
+	return txID, current - amount, nil
+}
+
+// Refund revierte un cargo, por ejemplo cuando el servicio upstream falló.
+// Cada cargo solo se puede reembolsar una vez.
+func Refund(debitTxID, description string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var debit models.LedgerEntry
+		err := tx.Where("tx_id = ? AND kind = ? AND amount < 0", debitTxID, models.LedgerDebit).First(&debit).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDebitNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.LedgerEntry{}).Where("kind = ? AND reference = ?", models.LedgerRefund, debitTxID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyRefunded
+		}
+		_, err = postTransfer(tx, models.AccountUsage, debit.Account, -debit.Amount, models.LedgerRefund, debitTxID, description)
+		return err
+	})
+}
+
+// GetStatement devuelve el extracto del usuario entre from (inclusive) y to
+// (exclusivo). Cualquiera de los dos puede ser nil.
+func GetStatement(username string, from, to *time.Time) (*Statement, error) {
+	account := models.UserAccount(username)
+	statement := &Statement{Account: account, From: from, To: to, Totals: map[string]int{}}
+
+	if from != nil {
+		err := DB.Model(&models.LedgerEntry{}).Where("account = ? AND created_at < ?", account, *from).
+			Select("COALESCE(SUM(amount), 0)").Scan(&statement.OpeningBalance).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := DB.Where("account = ?", account)
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+	if err := query.Order("created_at, id").Find(&statement.Entries).Error; err != nil {
+		return nil, err
+	}
+
+	statement.ClosingBalance = statement.OpeningBalance
+	for _, entry := range statement.Entries {
+		statement.Totals[entry.Kind] += entry.Amount
+		statement.ClosingBalance += entry.Amount
+	}
+	return statement, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDebit(t *testing.T) {
+	tests := []struct {
+		name          string
+		topUp         int
+		amount        int
+		wantErr       error
+		wantRemaining int
+	}{
+		{name: "saldo suficiente", topUp: 5, amount: 1, wantRemaining: 4},
+		{name: "saldo exacto", topUp: 1, amount: 1, wantRemaining: 0},
+		{name: "sin saldo", topUp: 0, amount: 1, wantErr: ErrInsufficientCredits},
+		{name: "saldo menor que el cargo", topUp: 2, amount: 3, wantErr: ErrInsufficientCredits},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestDB(t)
+			if tt.topUp > 0 {
+				if _, err := TopUp("rick", tt.topUp, "recarga"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			txID, remaining, err := Debit("rick", tt.amount, "GET /api/v1/characters")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			current, _ := Balance("rick")
+			if tt.wantErr != nil {
+				if txID != "" || current != tt.topUp {
+					t.Fatalf("cargo rechazado registrado: tx %q, saldo %d", txID, current)
+				}
+				return
+			}
+			if remaining != tt.wantRemaining || current != tt.wantRemaining {
+				t.Fatalf("restante = %d, saldo = %d, se esperaba %d", remaining, current, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestRefund(t *testing.T) {
+	setupTestDB(t)
+	if _, err := TopUp("rick", 3, "recarga"); err != nil {
+		t.Fatal(err)
+	}
+	txID, _, err := Debit("rick", 1, "GET /api/v1/characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		txID    string
+		wantErr error
+	}{
+		{"reembolso", txID, nil},
+		{"segundo reembolso", txID, ErrAlreadyRefunded},
+		{"cargo inexistente", "no-existe", ErrDebitNotFound},
+	}
+	for _, tt := range tests {
+		if err := Refund(tt.txID, "upstream 502"); !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: error = %v, se esperaba %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if current, _ := Balance("rick"); current != 3 {
+		t.Fatalf("saldo = %d, se esperaba 3", current)
+	}
+
+	statement, err := GetStatement("rick", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.ClosingBalance != 3 || len(statement.Entries) != 3 {
+		t.Fatalf("extracto = %+v", statement)
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAlreadyInOrg  = errors.New("el usuario ya pertenece a una organización")
+	ErrQuotaExceeded = errors.New("cuota de la organización agotada")
+	ErrLastOrgAdmin  = errors.New("la organización debe conservar al menos un administrador")
+)
+
+// orgInvitationTTL es el tiempo que una invitación a una organización sigue vigente
+const orgInvitationTTL = 7 * 24 * time.Hour
+
+// monthStart devuelve el inicio del mes de t, que es cuando se reinicia la cuota
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// CreateOrganization crea la organización y agrega al creador como administrador
+func CreateOrganization(name, plan, owner string) (*models.Organization, error) {
+	org := models.Organization{Name: name, Plan: plan, PeriodStart: monthStart(time.Now())}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.OrgMembership{}).Where("username = ?", owner).Count(&count)
+		if count > 0 {
+			return ErrAlreadyInOrg
+		}
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrgMembership{OrgID: org.ID, Username: owner, Role: models.OrgRoleAdmin}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetOrganization busca una organización por su id
+func GetOrganization(id int) (*models.Organization, error) {
+	var org models.Organization
+	if err := DB.First(&org, id).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetMembership devuelve la membresía del usuario, si tiene
+func GetMembership(username string) (*models.OrgMembership, error) {
+	var membership models.OrgMembership
+	if err := DB.Where("username = ?", username).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// ListMembers devuelve los miembros de la organización
+func ListMembers(orgID int) ([]models.OrgMembership, error) {
+	var members []models.OrgMembership
+	err := DB.Where("org_id = ?", orgID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+// InviteMember invita a un usuario existente a la organización. El usuario no
+// pasa a ser miembro, ni a consumir la cuota, hasta que acepta la invitación.
+// Repetir la invitación actualiza el rol y renueva la expiración.
+func InviteMember(orgID int, username, role, invitedBy string) (*models.OrgInvitation, error) {
+	if _, err := GetUserByUsername(username); err != nil {
+		return nil, err
+	}
+	var count int64
+	DB.Model(&models.OrgMembership{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return nil, ErrAlreadyInOrg
+	}
+	invitation := models.OrgInvitation{OrgID: orgID, Username: username}
+	err := DB.Where(&invitation).Assign(models.OrgInvitation{
+		Role:      role,
+		InvitedBy: invitedBy,
+		ExpiresAt: time.Now().Add(orgInvitationTTL),
+	}).FirstOrCreate(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListOrgInvitations devuelve las invitaciones vigentes del usuario
+func ListOrgInvitations(username string) ([]models.OrgInvitation, error) {
+	invitations := []models.OrgInvitation{}
+	err := DB.Where("username = ? AND expires_at > ?", username, time.Now()).Order("created_at").Find(&invitations).Error
+	return invitations, err
+}
+
+// AcceptOrgInvitation convierte la invitación en una membresía y descarta el
+// resto de invitaciones del usuario, que solo puede pertenecer a una organización
+func AcceptOrgInvitation(username string, id int) (*models.OrgMembership, error) {
+	var membership models.OrgMembership
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var invitation models.OrgInvitation
+		if err := tx.Where("id = ? AND username = ? AND expires_at > ?", id, username, time.Now()).First(&invitation).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.OrgMembership{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyInOrg
+		}
+		membership = models.OrgMembership{OrgID: invitation.OrgID, Username: username, Role: invitation.Role}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+		return tx.Where("username = ?", username).Delete(&models.OrgInvitation{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// DeclineOrgInvitation elimina una invitación del usuario
+func DeclineOrgInvitation(username string, id int) error {
+	result := DB.Where("id = ? AND username = ?", id, username).Delete(&models.OrgInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// removeMembership borra la membresía sin dejar la organización sin
+// administradores mientras tenga otros miembros
+func removeMembership(tx *gorm.DB, membership *models.OrgMembership) error {
+	if membership.Role == models.OrgRoleAdmin {
+		var admins, members int64
+		if err := tx.Model(&models.OrgMembership{}).Where("org_id = ? AND role = ?", membership.OrgID, models.OrgRoleAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.OrgMembership{}).Where("org_id = ?", membership.OrgID).Count(&members).Error; err != nil {
+			return err
+		}
+		if admins <= 1 && members > 1 {
+			return ErrLastOrgAdmin
+		}
+	}
+	return tx.Delete(membership).Error
+}
+
+// RemoveMember quita al usuario de la organización y revoca sus tokens, que
+// llevan el id de la organización en sus claims
+func RemoveMember(orgID int, username string) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var membership models.OrgMembership
+		if err := tx.Where("org_id = ? AND username = ?", orgID, username).First(&membership).Error; err != nil {
+			return err
+		}
+		return removeMembership(tx, &membership)
+	})
+	if err != nil {
+		return err
+	}
+	return RevokeUserTokens(username)
+}
+
+// LeaveOrganization saca al usuario de su organización y revoca sus tokens.
+// Devuelve el id de la organización que dejó.
+func LeaveOrganization(username string) (int, error) {
+	var membership models.OrgMembership
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ?", username).First(&membership).Error; err != nil {
+			return err
+		}
+		return removeMembership(tx, &membership)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return membership.OrgID, RevokeUserTokens(username)
+}
+
+// SetOrganizationPlan cambia el plan de la organización
+func SetOrganizationPlan(orgID int, plan string) error {
+	result := DB.Model(&models.Organization{}).Where("id = ?", orgID).Update("plan", plan)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// resetPeriodIfNeeded reinicia el consumo si empezó un nuevo mes
+func resetPeriodIfNeeded(tx *gorm.DB, org *models.Organization) error {
+	current := monthStart(time.Now())
+	if !org.PeriodStart.Before(current) {
+		return nil
+	}
+	org.Used = 0
+	org.PeriodStart = current
+	return tx.Model(org).Updates(map[string]interface{}{"used": 0, "period_start": current}).Error
+}
+
+// OrgQuotaRemaining devuelve las peticiones que le quedan a la organización en el mes
+func OrgQuotaRemaining(orgID int) (int, error) {
+	var remaining int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var org models.Organization
+		if err := tx.First(&org, orgID).Error; err != nil {
+			return err
+		}
+		if err := resetPeriodIfNeeded(tx, &org); err != nil {
+			return err
+		}
+		remaining = PlanQuota(org.Plan) - org.Used
+		return nil
+	})
+	return remaining, err
+}
+
+// consumeOrgQuota descuenta una petición de la cuota compartida dentro de la
+// transacción y devuelve lo que queda
+func consumeOrgQuota(tx *gorm.DB, orgID int) (int, error) {
+	var org models.Organization
+	if err := tx.First(&org, orgID).Error; err != nil {
+		return 0, err
+	}
+	if err := resetPeriodIfNeeded(tx, &org); err != nil {
+		return 0, err
+	}
+	quota := PlanQuota(org.Plan)
+	result := tx.Model(&models.Organization{}).
+		Where("id = ? AND used < ?", orgID, quota).
+		Update("used", gorm.Expr("used + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrQuotaExceeded
+	}
+	return quota - org.Used - 1, nil
+}
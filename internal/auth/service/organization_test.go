@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+func createUsers(t *testing.T, usernames ...string) {
+	t.Helper()
+	for _, username := range usernames {
+		if err := CreateUser(username, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOrgInvitationRequiresAcceptance(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick", "morty", "summer")
+	org, err := CreateOrganization("citadel", "free", "rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := CreateOrganization("federation", "free", "summer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InviteMember(org.ID, "nadie", models.OrgRoleMember, "rick"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("invitar a un usuario inexistente: %v", err)
+	}
+	if _, err := InviteMember(org.ID, "summer", models.OrgRoleMember, "rick"); !errors.Is(err, ErrAlreadyInOrg) {
+		t.Fatalf("invitar a un miembro de otra organización: %v", err)
+	}
+	invitation, err := InviteMember(org.ID, "morty", models.OrgRoleMember, "rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Repetir la invitación la actualiza en lugar de duplicarla
+	again, err := InviteMember(org.ID, "morty", models.OrgRoleAdmin, "rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != invitation.ID || again.Role != models.OrgRoleAdmin {
+		t.Fatalf("invitación repetida = %+v", again)
+	}
+	// Una segunda organización también lo invita
+	if _, err := InviteMember(other.ID, "morty", models.OrgRoleMember, "summer"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := GetMembership("morty"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("la invitación no debe crear la membresía: %v", err)
+	}
+	invitations, err := ListOrgInvitations("morty")
+	if err != nil || len(invitations) != 2 {
+		t.Fatalf("invitaciones = %v, %v", invitations, err)
+	}
+
+	if _, err := AcceptOrgInvitation("summer", invitation.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("aceptar la invitación de otro usuario: %v", err)
+	}
+	membership, err := AcceptOrgInvitation("morty", invitation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if membership.OrgID != org.ID || membership.Role != models.OrgRoleAdmin {
+		t.Fatalf("membresía = %+v", membership)
+	}
+	// Aceptar descarta el resto de invitaciones
+	if invitations, _ := ListOrgInvitations("morty"); len(invitations) != 0 {
+		t.Fatalf("quedan invitaciones: %v", invitations)
+	}
+}
+
+func TestLastOrgAdmin(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick", "morty")
+	org, err := CreateOrganization("citadel", "free", "rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitation, err := InviteMember(org.ID, "morty", models.OrgRoleMember, "rick")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcceptOrgInvitation("morty", invitation.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LeaveOrganization("rick"); !errors.Is(err, ErrLastOrgAdmin) {
+		t.Fatalf("el último administrador salió: %v", err)
+	}
+	if err := RemoveMember(org.ID, "rick"); !errors.Is(err, ErrLastOrgAdmin) {
+		t.Fatalf("se quitó al último administrador: %v", err)
+	}
+	if _, err := LeaveOrganization("morty"); err != nil {
+		t.Fatal(err)
+	}
+	// Sin más miembros el administrador sí puede salir
+	if orgID, err := LeaveOrganization("rick"); err != nil || orgID != org.ID {
+		t.Fatalf("LeaveOrganization = %d, %v", orgID, err)
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+// planQuotas es el número de peticiones mensuales que incluye cada plan
+var planQuotas = map[string]int{
+	"free":       1000,
+	"pro":        50000,
+	"enterprise": 1000000,
+}
+
+// ValidPlan indica si el plan existe
+func ValidPlan(plan string) bool {
+	_, ok := planQuotas[plan]
+	return ok
+}
+
+// PlanQuota devuelve la cuota mensual del plan, o la del plan free si no existe
+func PlanQuota(plan string) int {
+	if quota, ok := planQuotas[plan]; ok {
+		return quota
+	}
+	return planQuotas["free"]
+}
+
+// planSignupCredits son los créditos que recibe un usuario nuevo según su plan
+var planSignupCredits = map[string]int{
+	"free":       100,
+	"pro":        1000,
+	"enterprise": 10000,
+}
+
+// PlanSignupCredits devuelve los créditos iniciales del plan
+func PlanSignupCredits(plan string) int {
+	if credits, ok := planSignupCredits[plan]; ok {
+		return credits
+	}
+	return planSignupCredits["free"]
+}
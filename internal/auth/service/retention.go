@@ -0,0 +1,177 @@
+package service
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+// PurgeReport resume lo que se eliminó (o se eliminaría en modo simulación)
+type PurgeReport struct {
+	DryRun      bool      `json:"dry_run"`
+	Cutoff      time.Time `json:"cutoff"`
+	Users       []string  `json:"users"`
+	Tokens      int64     `json:"tokens"`
+	AuditEvents int64     `json:"audit_events"`
+	Invites     int64     `json:"invites"`
+	// Secretos de cuentas de servicio cuyo período de gracia terminó
+	ServiceAccountSecrets int64 `json:"service_account_secrets"`
+}
+
+// RetentionWindow devuelve la ventana de retención configurada en
+// DATA_RETENTION_DAYS (30 días por defecto)
+func RetentionWindow() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("DATA_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DeactivateUser desactiva la cuenta y revoca sus tokens
+func DeactivateUser(username string) error {
+	now := time.Now()
+	result := DB.Model(&models.User{}).
+		Where("username = ? AND deactivated_at IS NULL", username).
+		Update("deactivated_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return RevokeUserTokens(username)
+}
+
+// ReactivateUser reactiva una cuenta que aún no ha sido purgada
+func ReactivateUser(username string) error {
+	result := DB.Model(&models.User{}).
+		Where("username = ? AND deactivated_at IS NOT NULL", username).
+		Update("deactivated_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Purge elimina las cuentas desactivadas antes de la ventana de retención, los
+// tokens expirados y los eventos de auditoría e invitaciones antiguas. Con
+// dryRun solo calcula lo que se eliminaría.
+func Purge(retention time.Duration, dryRun bool) (*PurgeReport, error) {
+	now := time.Now()
+	report := &PurgeReport{DryRun: dryRun, Cutoff: now.Add(-retention), Users: []string{}}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).
+			Where("deactivated_at IS NOT NULL AND deactivated_at < ?", report.Cutoff).
+			Pluck("username", &report.Users).Error; err != nil {
+			return err
+		}
+
+		// Cada consulta se construye de nuevo porque las sentencias de gorm
+		// no se pueden reutilizar después de ejecutarse
+		tokens := func() *gorm.DB {
+			q := tx.Where("expires_at < ?", now)
+			if len(report.Users) > 0 {
+				q = q.Or("username IN ?", report.Users)
+			}
+			return q
+		}
+		audit := func() *gorm.DB {
+			q := tx.Where("created_at < ?", report.Cutoff)
+			if len(report.Users) > 0 {
+				q = q.Or("username IN ?", report.Users)
+			}
+			return q
+		}
+		invites := func() *gorm.DB {
+			return tx.Where("expires_at < ?", report.Cutoff)
+		}
+
+		if err := tokens().Model(&models.Token{}).Count(&report.Tokens).Error; err != nil {
+			return err
+		}
+		if err := audit().Model(&models.AuditEvent{}).Count(&report.AuditEvents).Error; err != nil {
+			return err
+		}
+		if err := invites().Model(&models.Invite{}).Count(&report.Invites).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ServiceAccountSecret{}).Where("expires_at < ?", now).
+			Count(&report.ServiceAccountSecrets).Error; err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+
+		if len(report.Users) > 0 {
+			if err := tx.Where("username IN ?", report.Users).Delete(&models.User{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("username IN ?", report.Users).Delete(&models.OrgMembership{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("username IN ?", report.Users).Delete(&models.OrgInvitation{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("username IN ?", report.Users).Delete(&models.KnownDevice{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("username IN ?", report.Users).Delete(&models.UsageWindow{}).Error; err != nil {
+				return err
+			}
+			if err := deleteLedgerEntries(tx, report.Users); err != nil {
+				return err
+			}
+		}
+		if err := tokens().Delete(&models.Token{}).Error; err != nil {
+			return err
+		}
+		if err := audit().Delete(&models.AuditEvent{}).Error; err != nil {
+			return err
+		}
+		if err := invites().Delete(&models.Invite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.ServiceAccountSecret{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.LoginChallenge{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.OrgInvitation{}).Error; err != nil {
+			return err
+		}
+		// Las autorizaciones de dispositivo solo son útiles durante unos minutos
+		return tx.Where("expires_at < ?", now).Delete(&models.DeviceAuthorization{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// StartRetentionJob ejecuta Purge periódicamente en segundo plano
+func StartRetentionJob(retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			report, err := Purge(retention, false)
+			if err != nil {
+				log.Printf("[AUTH] Error en la purga de retención: %v", err)
+				continue
+			}
+			log.Printf("[AUTH] Purga de retención: %d usuarios, %d tokens, %d eventos de auditoría, %d invitaciones, %d secretos de servicio",
+				len(report.Users), report.Tokens, report.AuditEvents, report.Invites, report.ServiceAccountSecrets)
+		}
+	}()
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+func TestDeactivateAndReactivateUser(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick")
+	if err := StoreToken("tok", "rick", time.Now().Add(time.Hour), "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeactivateUser("rick"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := GetUserByUsername("rick"); user.DeactivatedAt == nil {
+		t.Fatal("la cuenta no quedó desactivada")
+	}
+	if TokenExists("tok") {
+		t.Fatal("desactivar la cuenta debe revocar sus tokens")
+	}
+	if err := DeactivateUser("rick"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("desactivar dos veces: error = %v", err)
+	}
+
+	if err := ReactivateUser("rick"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := GetUserByUsername("rick"); user.DeactivatedAt != nil {
+		t.Fatal("la cuenta sigue desactivada")
+	}
+	if err := ReactivateUser("rick"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("reactivar una cuenta activa: error = %v", err)
+	}
+}
+
+func TestPurgeRemovesExpiredAccounts(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick", "morty")
+	for _, username := range []string{"rick", "morty"} {
+		user, _ := GetUserByUsername(username)
+		if err := EnsureSignupGrant(user); err != nil {
+			t.Fatal(err)
+		}
+		if err := DeactivateUser(username); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Solo la desactivación de rick queda fuera de la ventana de retención
+	old := time.Now().Add(-48 * time.Hour)
+	if err := DB.Model(&models.User{}).Where("username = ?", "rick").Update("deactivated_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Purge(24*time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Users) != 1 || report.Users[0] != "rick" {
+		t.Fatalf("usuarios a purgar = %v, se esperaba [rick]", report.Users)
+	}
+	if _, err := GetUserByUsername("rick"); err != nil {
+		t.Fatal("la simulación no debe borrar nada")
+	}
+
+	if _, err := Purge(24*time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetUserByUsername("rick"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("rick sigue registrado: %v", err)
+	}
+	if _, err := GetUserByUsername("morty"); err != nil {
+		t.Fatal("morty está dentro de la ventana y no debe purgarse")
+	}
+	if credits, _ := Balance("rick"); credits != 0 {
+		t.Fatalf("saldo de rick tras la purga = %d", credits)
+	}
+	if credits, _ := Balance("morty"); credits != PlanSignupCredits("free") {
+		t.Fatalf("saldo de morty tras la purga = %d", credits)
+	}
+}
+
+func TestPurgedUsernameGetsSignupGrant(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick")
+	user, _ := GetUserByUsername("rick")
+	if err := EnsureSignupGrant(user); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TopUp("rick", 50, "recarga"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeactivateUser("rick"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Purge(0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	createUsers(t, "rick")
+	user, _ = GetUserByUsername("rick")
+	if err := EnsureSignupGrant(user); err != nil {
+		t.Fatal(err)
+	}
+	if credits, _ := Balance("rick"); credits != PlanSignupCredits("free") {
+		t.Fatalf("saldo tras registrarse de nuevo = %d, se esperaba %d", credits, PlanSignupCredits("free"))
+	}
+	var unbalanced int64
+	if err := DB.Model(&models.LedgerEntry{}).Select("COALESCE(SUM(amount), 0)").Scan(&unbalanced).Error; err != nil {
+		t.Fatal(err)
+	}
+	if unbalanced != 0 {
+		t.Fatalf("el libro no cuadra: suma = %d", unbalanced)
+	}
+}
+
+func TestDeleteUserRemovesLedger(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick", "morty")
+	for _, username := range []string{"rick", "morty"} {
+		user, _ := GetUserByUsername(username)
+		if err := EnsureSignupGrant(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DeleteUser("rick"); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := DB.Model(&models.LedgerEntry{}).Where("account = ?", models.UserAccount("rick")).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("quedan %d apuntes de rick", count)
+	}
+	if credits, _ := Balance("morty"); credits != PlanSignupCredits("free") {
+		t.Fatalf("saldo de morty = %d", credits)
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrUnsupportedFilter = errors.New("filtro no soportado")
+
+// Filter es un filtro SCIM simple del tipo `atributo op "valor"`
+type Filter struct {
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+// ParseFilter interpreta filtros con los operadores eq, co y sw, que son los
+// que usan los proveedores de identidad habituales
+func ParseFilter(raw string) (*Filter, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(raw, " ", 3)
+	if len(parts) != 3 {
+		return nil, ErrUnsupportedFilter
+	}
+	op := strings.ToLower(parts[1])
+	if op != "eq" && op != "co" && op != "sw" {
+		return nil, ErrUnsupportedFilter
+	}
+	value := strings.TrimSpace(parts[2])
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return nil, ErrUnsupportedFilter
+	}
+	return &Filter{Attribute: parts[0], Operator: op, Value: value[1 : len(value)-1]}, nil
+}
+
+// apply agrega el filtro a la consulta. columns traduce los atributos SCIM a columnas.
+func (f *Filter) apply(query *gorm.DB, columns map[string]string) (*gorm.DB, error) {
+	if f == nil {
+		return query, nil
+	}
+	column, ok := columns[strings.ToLower(f.Attribute)]
+	if !ok {
+		return nil, ErrUnsupportedFilter
+	}
+	switch f.Operator {
+	case "eq":
+		return query.Where(column+" = ?", f.Value), nil
+	case "co":
+		return query.Where(column+` LIKE ? ESCAPE '\'`, "%"+escapeLike(f.Value)+"%"), nil
+	default:
+		return query.Where(column+` LIKE ? ESCAPE '\'`, escapeLike(f.Value)+"%"), nil
+	}
+}
+
+// likeEscaper escapa los comodines de LIKE para que co y sw comparen el
+// valor literal: "a_b" no debe coincidir con "axb"
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+var userFilterColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"externalid": "external_id",
+}
+
+var groupFilterColumns = map[string]string{
+	"id":          "id",
+	"displayname": "display_name",
+}
+
+// ListUsersPage devuelve una página de usuarios filtrados y el total de resultados
+func ListUsersPage(filter *Filter, offset, limit int) ([]models.User, int64, error) {
+	query, err := filter.apply(DB.Model(&models.User{}), userFilterColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	query, _ = filter.apply(DB.Model(&models.User{}), userFilterColumns)
+	var users []models.User
+	err = query.Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+// GetUserByID busca un usuario por su id
+func GetUserByID(id int) (*models.User, error) {
+	var user models.User
+	if err := DB.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateProvisionedUser crea un usuario aprovisionado externamente. Sin
+// contraseña, el usuario queda con origen scim y una contraseña inutilizable.
+func CreateProvisionedUser(username, externalID, password string) (*models.User, error) {
+	var count int64
+	if err := DB.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+	source := models.SourceLocal
+	if password == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		password = hex.EncodeToString(random)
+		source = models.SourceSCIM
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{Username: username, Password: string(hash), Role: models.RoleUser, Plan: "free",
+		Source: source, ExternalID: externalID}
+	if err := DB.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUserFields actualiza los campos indicados del usuario
+func UpdateUserFields(user *models.User, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return DB.Model(user).Updates(fields).Error
+}
+
+// DeleteUser elimina al usuario, su membresía, sus créditos y sus tokens
+func DeleteUser(username string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("username = ?", username).Delete(&models.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("username = ?", username).Delete(&models.OrgMembership{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("username = ?", username).Delete(&models.KnownDevice{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("username = ?", username).Delete(&models.UsageWindow{}).Error; err != nil {
+			return err
+		}
+		if err := deleteLedgerEntries(tx, []string{username}); err != nil {
+			return err
+		}
+		return tx.Where("username = ?", username).Delete(&models.Token{}).Error
+	})
+}
+
+// EnsureDefaultGroups crea los grupos de los roles predefinidos
+func EnsureDefaultGroups() error {
+	for _, role := range []string{models.RoleUser, models.RoleAdmin} {
+		if err := DB.Where(models.Group{DisplayName: role}).FirstOrCreate(&models.Group{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListGroupsPage devuelve una página de grupos filtrados y el total de resultados
+func ListGroupsPage(filter *Filter, offset, limit int) ([]models.Group, int64, error) {
+	query, err := filter.apply(DB.Model(&models.Group{}), groupFilterColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	query, _ = filter.apply(DB.Model(&models.Group{}), groupFilterColumns)
+	var groups []models.Group
+	err = query.Order("id").Offset(offset).Limit(limit).Find(&groups).Error
+	return groups, total, err
+}
+
+// GetGroup busca un grupo por su id
+func GetGroup(id int) (*models.Group, error) {
+	var group models.Group
+	if err := DB.First(&group, id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetGroupByName busca el grupo de un rol
+func GetGroupByName(displayName string) (*models.Group, error) {
+	var group models.Group
+	if err := DB.Where("display_name = ?", displayName).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// CreateGroup crea un grupo, es decir, un rol nuevo
+func CreateGroup(displayName string) (*models.Group, error) {
+	group := models.Group{DisplayName: displayName}
+	if err := DB.Create(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GroupMembers devuelve los usuarios con el rol del grupo
+func GroupMembers(group *models.Group) ([]models.User, error) {
+	var users []models.User
+	err := DB.Where("role = ?", group.DisplayName).Order("id").Find(&users).Error
+	return users, err
+}
+
+// usernamesWhere devuelve los nombres de los usuarios que cumplen la condición
+func usernamesWhere(tx *gorm.DB, query string, args ...interface{}) ([]string, error) {
+	var usernames []string
+	err := tx.Model(&models.User{}).Where(query, args...).Pluck("username", &usernames).Error
+	return usernames, err
+}
+
+// SetGroupMembership asigna (o quita, devolviendo al rol user) el rol del grupo
+// a los usuarios indicados por id. Como el rol es único, agregar un usuario a
+// un grupo lo quita de cualquier otro. Los tokens de los usuarios cuyo rol
+// cambia se revocan, porque llevan el rol anterior en sus claims.
+func SetGroupMembership(group *models.Group, userIDs []int, member bool) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var affected []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if member {
+			affected, err = usernamesWhere(tx, "id IN ? AND role <> ?", userIDs, group.DisplayName)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.User{}).Where("id IN ?", userIDs).Update("role", group.DisplayName).Error
+		}
+		affected, err = usernamesWhere(tx, "id IN ? AND role = ?", userIDs, group.DisplayName)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id IN ? AND role = ?", userIDs, group.DisplayName).
+			Update("role", models.RoleUser).Error
+	})
+	if err != nil {
+		return err
+	}
+	return revokeTokensOf(affected)
+}
+
+// RenameGroup cambia el nombre del grupo y el rol de sus miembros, y revoca
+// sus tokens
+func RenameGroup(group *models.Group, displayName string) error {
+	var affected []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		affected, err = usernamesWhere(tx, "role = ?", group.DisplayName)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("role = ?", group.DisplayName).Update("role", displayName).Error; err != nil {
+			return err
+		}
+		group.DisplayName = displayName
+		return tx.Model(group).Update("display_name", displayName).Error
+	})
+	if err != nil {
+		return err
+	}
+	return revokeTokensOf(affected)
+}
+
+// DeleteGroup elimina el grupo, devuelve a sus miembros al rol user y revoca
+// sus tokens. Los grupos de los roles predefinidos no se pueden eliminar.
+func DeleteGroup(group *models.Group) error {
+	if group.DisplayName == models.RoleUser || group.DisplayName == models.RoleAdmin {
+		return errors.New("no se puede eliminar un grupo predefinido")
+	}
+	var affected []string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		affected, err = usernamesWhere(tx, "role = ?", group.DisplayName)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("role = ?", group.DisplayName).Update("role", models.RoleUser).Error; err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+	if err != nil {
+		return err
+	}
+	return revokeTokensOf(affected)
+}
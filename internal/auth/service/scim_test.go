@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    *Filter
+		wantErr bool
+	}{
+		{raw: "", want: nil},
+		{raw: `userName eq "rick"`, want: &Filter{"userName", "eq", "rick"}},
+		{raw: `  displayName CO "council of ricks" `, want: &Filter{"displayName", "co", "council of ricks"}},
+		{raw: `externalId sw "abc"`, want: &Filter{"externalId", "sw", "abc"}},
+		{raw: `userName eq ""`, want: &Filter{"userName", "eq", ""}},
+		{raw: `userName ne "rick"`, wantErr: true},
+		{raw: `userName eq rick`, wantErr: true},
+		{raw: `userName eq`, wantErr: true},
+		{raw: `userName eq "rick`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.raw)
+		if tt.wantErr {
+			if !errors.Is(err, ErrUnsupportedFilter) {
+				t.Errorf("ParseFilter(%q) error = %v", tt.raw, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFilter(%q) error inesperado: %v", tt.raw, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseFilter(%q) = %+v, se esperaba %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestFilterLikeIsLiteral(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick_c137", "rickxc137", "morty%1", "morty12")
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`userName co "_c"`, []string{"rick_c137"}},
+		{`userName sw "rick_"`, []string{"rick_c137"}},
+		{`userName co "%"`, []string{"morty%1"}},
+		{`userName sw "morty"`, []string{"morty%1", "morty12"}},
+		{`userName eq "rickxc137"`, []string{"rickxc137"}},
+	}
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users, total, err := ListUsersPage(filter, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, u := range users {
+			got = append(got, u.Username)
+		}
+		if int(total) != len(tt.want) || len(got) != len(tt.want) {
+			t.Errorf("%s: %v (total %d), se esperaba %v", tt.filter, got, total, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: %v, se esperaba %v", tt.filter, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestGroupChangesRevokeTokens(t *testing.T) {
+	setupTestDB(t)
+	createUsers(t, "rick", "morty", "summer")
+	group, err := CreateGroup("scientists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(username string) string {
+		token := "token-" + username + time.Now().Format("150405.000000000")
+		if err := StoreToken(token, username, time.Now().Add(time.Hour), "", ""); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	userID := func(username string) int {
+		user, err := GetUserByUsername(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+
+	rick, summer := issue("rick"), issue("summer")
+	if err := SetGroupMembership(group, []int{userID("rick")}, true); err != nil {
+		t.Fatal(err)
+	}
+	if TokenExists(rick) || !TokenExists(summer) {
+		t.Fatal("agregar al grupo debe revocar solo los tokens del usuario afectado")
+	}
+
+	rick = issue("rick")
+	if err := RenameGroup(group, "council"); err != nil {
+		t.Fatal(err)
+	}
+	if TokenExists(rick) {
+		t.Fatal("renombrar el grupo debe revocar los tokens de sus miembros")
+	}
+
+	rick = issue("rick")
+	if err := DeleteGroup(group); err != nil {
+		t.Fatal(err)
+	}
+	if TokenExists(rick) || !TokenExists(summer) {
+		t.Fatal("eliminar el grupo debe revocar solo los tokens de sus miembros")
+	}
+	if user, _ := GetUserByUsername("rick"); user.Role != models.RoleUser {
+		t.Fatalf("rol tras eliminar el grupo = %q", user.Role)
+	}
+}
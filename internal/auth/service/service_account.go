@@ -0,0 +1,161 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidClientSecret = errors.New("cuenta de servicio o secreto inválido")
+
+// ServiceAccountInfo es una cuenta de servicio con el estado de sus secretos
+type ServiceAccountInfo struct {
+	models.ServiceAccount
+	Secrets []models.ServiceAccountSecret `json:"secrets"`
+}
+
+// newServiceAccountSecret genera un secreto con el formato sas_<prefijo>_<aleatorio>.
+// El prefijo permite localizar el secreto sin guardarlo en claro.
+func newServiceAccountSecret(accountID int) (string, *models.ServiceAccountSecret, error) {
+	prefix := make([]byte, 6)
+	random := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, err
+	}
+	secret := "sas_" + hex.EncodeToString(prefix) + "_" + hex.EncodeToString(random)
+	return secret, &models.ServiceAccountSecret{
+		AccountID: accountID,
+		Prefix:    hex.EncodeToString(prefix),
+		Hash:      hashToken(secret),
+	}, nil
+}
+
+// CreateServiceAccount crea la cuenta y su primer secreto, que se devuelve en claro
+func CreateServiceAccount(name, description, plan string) (*models.ServiceAccount, string, error) {
+	account := models.ServiceAccount{Name: name, Description: description, Plan: plan}
+	var secret string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&account).Error; err != nil {
+			return err
+		}
+		var stored *models.ServiceAccountSecret
+		var err error
+		secret, stored, err = newServiceAccountSecret(account.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Create(stored).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &account, secret, nil
+}
+
+// RotateServiceAccountSecret emite un secreto nuevo. Los secretos vigentes
+// siguen funcionando durante el período de gracia y después expiran.
+func RotateServiceAccountSecret(name string, grace time.Duration) (string, error) {
+	return rotateServiceAccountSecret(name, grace, nil)
+}
+
+// rotateServiceAccountSecret rota el secreto y, si deliver no es nil, lo
+// entrega dentro de la transacción: si la entrega falla la rotación se
+// deshace y los secretos anteriores no se acortan
+func rotateServiceAccountSecret(name string, grace time.Duration, deliver func(secret string) error) (string, error) {
+	var secret string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var account models.ServiceAccount
+		if err := tx.Where("name = ?", name).First(&account).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		expiresAt := now.Add(grace)
+		// Solo se acorta la vida de los secretos; uno que ya expira antes se mantiene
+		if err := tx.Model(&models.ServiceAccountSecret{}).
+			Where("account_id = ? AND (expires_at IS NULL OR expires_at > ?)", account.ID, expiresAt).
+			Update("expires_at", expiresAt).Error; err != nil {
+			return err
+		}
+		var stored *models.ServiceAccountSecret
+		var err error
+		secret, stored, err = newServiceAccountSecret(account.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(stored).Error; err != nil {
+			return err
+		}
+		if deliver != nil {
+			return deliver(secret)
+		}
+		return nil
+	})
+	return secret, err
+}
+
+// AuthenticateServiceAccount verifica el secreto y registra su último uso
+func AuthenticateServiceAccount(name, secret string) (*models.ServiceAccount, error) {
+	parts := strings.SplitN(secret, "_", 3)
+	if len(parts) != 3 || parts[0] != "sas" {
+		return nil, ErrInvalidClientSecret
+	}
+	var stored models.ServiceAccountSecret
+	err := DB.Where("prefix = ? AND (expires_at IS NULL OR expires_at > ?)", parts[1], time.Now()).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidClientSecret
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(hashToken(secret))) != 1 {
+		return nil, ErrInvalidClientSecret
+	}
+	var account models.ServiceAccount
+	if err := DB.First(&account, stored.AccountID).Error; err != nil {
+		return nil, ErrInvalidClientSecret
+	}
+	if account.Name != name {
+		return nil, ErrInvalidClientSecret
+	}
+	now := time.Now()
+	DB.Model(&stored).Update("last_used_at", &now)
+	return &account, nil
+}
+
+// ListServiceAccounts devuelve las cuentas con sus secretos no expirados
+func ListServiceAccounts() ([]ServiceAccountInfo, error) {
+	var accounts []models.ServiceAccount
+	if err := DB.Order("name").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	infos := make([]ServiceAccountInfo, 0, len(accounts))
+	for _, account := range accounts {
+		info := ServiceAccountInfo{ServiceAccount: account}
+		err := DB.Where("account_id = ? AND (expires_at IS NULL OR expires_at > ?)", account.ID, time.Now()).
+			Order("created_at").Find(&info.Secrets).Error
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// ServiceAccountsDueForRotation devuelve las cuentas cuyo secreto más reciente
+// es más antiguo que maxAge
+func ServiceAccountsDueForRotation(maxAge time.Duration) ([]models.ServiceAccount, error) {
+	var accounts []models.ServiceAccount
+	err := DB.Where("id NOT IN (?)",
+		DB.Model(&models.ServiceAccountSecret{}).Select("account_id").Where("created_at > ?", time.Now().Add(-maxAge)),
+	).Order("name").Find(&accounts).Error
+	return accounts, err
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServiceAccountSecretsDir es el directorio en el que se deja el secreto
+// vigente de cada cuenta de servicio, un archivo por cuenta que leen los
+// procesos batch (SERVICE_ACCOUNT_SECRETS_DIR). Vacío desactiva la rotación
+// automática.
+func ServiceAccountSecretsDir() string {
+	return os.Getenv("SERVICE_ACCOUNT_SECRETS_DIR")
+}
+
+// WriteServiceAccountSecret escribe el secreto en dir/<nombre> con permisos
+// 0600. El archivo se reemplaza de forma atómica, así que un proceso que lo
+// lea durante la rotación ve el secreto anterior o el nuevo.
+func WriteServiceAccountSecret(dir, name, secret string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("nombre de cuenta de servicio inválido para un archivo: %q", name)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(secret + "\n"); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+// RotateDueServiceAccounts rota las cuentas cuyo secreto más reciente es más
+// antiguo que maxAge y entrega cada secreto nuevo con deliver. Una cuenta
+// cuya entrega falla no se rota y no impide rotar las demás. Devuelve las
+// cuentas rotadas.
+func RotateDueServiceAccounts(maxAge, grace time.Duration, deliver func(name, secret string) error) ([]string, error) {
+	accounts, err := ServiceAccountsDueForRotation(maxAge)
+	if err != nil {
+		return nil, err
+	}
+	rotated := []string{}
+	var errs []error
+	for _, account := range accounts {
+		name := account.Name
+		_, err := rotateServiceAccountSecret(name, grace, func(secret string) error {
+			return deliver(name, secret)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rotando %s: %w", name, err))
+			continue
+		}
+		rotated = append(rotated, name)
+	}
+	return rotated, errors.Join(errs...)
+}
+
+// StartServiceAccountRotationJob rota periódicamente en segundo plano los
+// secretos de las cuentas de servicio que superan maxAge y deja los nuevos
+// en dir
+func StartServiceAccountRotationJob(dir string, maxAge, grace, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			rotated, err := RotateDueServiceAccounts(maxAge, grace, func(name, secret string) error {
+				return WriteServiceAccountSecret(dir, name, secret)
+			})
+			if len(rotated) > 0 {
+				log.Printf("[AUTH] Secretos de cuentas de servicio rotados: %s", strings.Join(rotated, ", "))
+			}
+			if err != nil {
+				log.Printf("[AUTH] Error en la rotación de cuentas de servicio: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+)
+
+// ageSecrets hace que los secretos de la cuenta parezcan creados hace age
+func ageSecrets(t *testing.T, name string, age time.Duration) {
+	t.Helper()
+	var account models.ServiceAccount
+	if err := DB.Where("name = ?", name).First(&account).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Model(&models.ServiceAccountSecret{}).Where("account_id = ?", account.ID).
+		Update("created_at", time.Now().Add(-age)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateDueServiceAccounts(t *testing.T) {
+	setupTestDB(t)
+	dir := t.TempDir()
+	_, oldSecret, err := CreateServiceAccount("nightly-sync", "", "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := CreateServiceAccount("fresh-job", "", "free"); err != nil {
+		t.Fatal(err)
+	}
+	ageSecrets(t, "nightly-sync", 100*24*time.Hour)
+
+	rotated, err := RotateDueServiceAccounts(90*24*time.Hour, time.Hour, func(name, secret string) error {
+		return WriteServiceAccountSecret(dir, name, secret)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 1 || rotated[0] != "nightly-sync" {
+		t.Fatalf("rotadas = %v", rotated)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "nightly-sync"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSecret := strings.TrimSpace(string(data))
+	if info, _ := os.Stat(filepath.Join(dir, "nightly-sync")); info.Mode().Perm() != 0o600 {
+		t.Fatalf("permisos = %v", info.Mode().Perm())
+	}
+	// Durante el período de gracia valen los dos secretos
+	for _, secret := range []string{oldSecret, newSecret} {
+		if _, err := AuthenticateServiceAccount("nightly-sync", secret); err != nil {
+			t.Fatalf("secreto rechazado: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fresh-job")); !os.IsNotExist(err) {
+		t.Fatalf("se rotó una cuenta reciente: %v", err)
+	}
+}
+
+func TestRotateDueServiceAccountsDeliveryFailure(t *testing.T) {
+	setupTestDB(t)
+	_, oldSecret, err := CreateServiceAccount("nightly-sync", "", "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ageSecrets(t, "nightly-sync", 100*24*time.Hour)
+
+	errDisk := errors.New("disco lleno")
+	rotated, err := RotateDueServiceAccounts(90*24*time.Hour, time.Nanosecond, func(name, secret string) error {
+		return errDisk
+	})
+	if !errors.Is(err, errDisk) || len(rotated) != 0 {
+		t.Fatalf("rotadas = %v, error = %v", rotated, err)
+	}
+	// La rotación se deshizo: el secreto anterior no se acortó
+	time.Sleep(time.Millisecond)
+	if _, err := AuthenticateServiceAccount("nightly-sync", oldSecret); err != nil {
+		t.Fatalf("el secreto anterior dejó de valer: %v", err)
+	}
+	accounts, err := ListServiceAccounts()
+	if err != nil || len(accounts) != 1 || len(accounts[0].Secrets) != 1 {
+		t.Fatalf("cuentas = %+v, %v", accounts, err)
+	}
+}
+
+func TestWriteServiceAccountSecretName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", ".", "..", "../escape", `a\b`} {
+		if err := WriteServiceAccountSecret(dir, name, "sas_x_y"); err == nil {
+			t.Errorf("%q: se esperaba un error", name)
+		}
+	}
+}
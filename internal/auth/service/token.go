@@ -0,0 +1,133 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token no encontrado")
+	ErrTokenIdle     = errors.New("token expirado por inactividad")
+)
+
+// TokenMaxLifetime es la duración absoluta de un token (TOKEN_MAX_LIFETIME_HOURS,
+// por defecto 24). La actividad nunca extiende un token más allá de este límite.
+func TokenMaxLifetime() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("TOKEN_MAX_LIFETIME_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// TokenIdleTimeout es el tiempo sin validaciones tras el cual el token expira
+// (TOKEN_IDLE_TIMEOUT_MINUTES). 0, el valor por defecto, lo deshabilita.
+func TokenIdleTimeout() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("TOKEN_IDLE_TIMEOUT_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// idleDeadline calcula el fin de la ventana de inactividad sin superar el límite absoluto
+func idleDeadline(now, expiresAt time.Time) *time.Time {
+	idle := TokenIdleTimeout()
+	if idle == 0 {
+		return nil
+	}
+	deadline := now.Add(idle)
+	if deadline.After(expiresAt) {
+		deadline = expiresAt
+	}
+	return &deadline
+}
+
+// TokenBinding devuelve los modos y la huella a los que está ligado el token.
+// Ambos son cadenas vacías si el token no está ligado.
+func TokenBinding(token string) (string, string, error) {
+	var stored models.Token
+	err := DB.Select("binding_modes", "fingerprint").Where("hash = ?", hashToken(token)).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", ErrTokenNotFound
+	}
+	return stored.BindingModes, stored.Fingerprint, err
+}
+
+// hashToken devuelve el hash con el que se guarda un token en la base de datos
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreToken registra un token emitido y, si se indica, la huella del cliente
+// al que queda ligado
+func StoreToken(token, username string, expiresAt time.Time, bindingModes, fingerprint string) error {
+	return DB.Create(&models.Token{
+		Hash:          hashToken(token),
+		Username:      username,
+		ExpiresAt:     expiresAt,
+		IdleExpiresAt: idleDeadline(time.Now(), expiresAt),
+		BindingModes:  bindingModes,
+		Fingerprint:   fingerprint,
+	}).Error
+}
+
+// ExtendTokenIdle comprueba que el token no haya superado su ventana de
+// inactividad y la desliza hasta ahora más el timeout, sin pasar del límite
+// absoluto. Devuelve la nueva expiración efectiva del token.
+func ExtendTokenIdle(token string) (time.Time, error) {
+	var stored models.Token
+	if err := DB.Where("hash = ?", hashToken(token)).First(&stored).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, ErrTokenNotFound
+		}
+		return time.Time{}, err
+	}
+	now := time.Now()
+	if stored.IdleExpiresAt != nil && now.After(*stored.IdleExpiresAt) {
+		DB.Delete(&stored)
+		return time.Time{}, ErrTokenIdle
+	}
+	deadline := idleDeadline(now, stored.ExpiresAt)
+	if deadline == nil {
+		return stored.ExpiresAt, nil
+	}
+	if err := DB.Model(&stored).Update("idle_expires_at", deadline).Error; err != nil {
+		return time.Time{}, err
+	}
+	return *deadline, nil
+}
+
+// TokenExists indica si el token sigue registrado (no revocado ni expirado)
+func TokenExists(token string) bool {
+	var count int64
+	DB.Model(&models.Token{}).Where("hash = ?", hashToken(token)).Count(&count)
+	return count > 0
+}
+
+// RevokeToken elimina un token concreto, por ejemplo al cerrar sesión
+func RevokeToken(token string) error {
+	return DB.Where("hash = ?", hashToken(token)).Delete(&models.Token{}).Error
+}
+
+// RevokeUserTokens elimina todos los tokens emitidos para un usuario
+func RevokeUserTokens(username string) error {
+	return DB.Where("username = ?", username).Delete(&models.Token{}).Error
+}
+
+// revokeTokensOf elimina los tokens de varios usuarios, por ejemplo cuando
+// cambia el rol de todo un grupo y sus claims dejan de ser correctos
+func revokeTokensOf(usernames []string) error {
+	if len(usernames) == 0 {
+		return nil
+	}
+	return DB.Where("username IN ?", usernames).Delete(&models.Token{}).Error
+}
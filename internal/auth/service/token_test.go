@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+)
+
+// storedToken devuelve la fila guardada para un token
+func storedToken(t *testing.T, token string) models.Token {
+	t.Helper()
+	var stored models.Token
+	if err := DB.Where("hash = ?", hashToken(token)).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestExtendTokenIdleExpired(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("TOKEN_IDLE_TIMEOUT_MINUTES", "15")
+	if err := StoreToken("tok", "rick", time.Now().Add(time.Hour), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if err := DB.Model(&models.Token{}).Where("hash = ?", hashToken("tok")).Update("idle_expires_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExtendTokenIdle("tok"); !errors.Is(err, ErrTokenIdle) {
+		t.Fatalf("error = %v, se esperaba ErrTokenIdle", err)
+	}
+	if TokenExists("tok") {
+		t.Fatal("el token inactivo sigue registrado")
+	}
+	if _, err := ExtendTokenIdle("tok"); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("error = %v, se esperaba ErrTokenNotFound", err)
+	}
+}
+
+func TestExtendTokenIdleSlides(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("TOKEN_IDLE_TIMEOUT_MINUTES", "15")
+	if err := StoreToken("tok", "rick", time.Now().Add(time.Hour), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	// Simula que la última actividad fue hace 10 minutos
+	soon := time.Now().Add(5 * time.Minute)
+	if err := DB.Model(&models.Token{}).Where("hash = ?", hashToken("tok")).Update("idle_expires_at", soon).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	deadline, err := ExtendTokenIdle("tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deadline.Before(before.Add(15*time.Minute)) || deadline.After(time.Now().Add(15*time.Minute)) {
+		t.Fatalf("nueva expiración = %v, se esperaba ahora + 15m", deadline)
+	}
+	stored := storedToken(t, "tok")
+	if stored.IdleExpiresAt == nil || !stored.IdleExpiresAt.Equal(deadline) {
+		t.Fatalf("idle_expires_at = %v, se esperaba %v", stored.IdleExpiresAt, deadline)
+	}
+}
+
+func TestExtendTokenIdleCappedByAbsoluteExpiry(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("TOKEN_IDLE_TIMEOUT_MINUTES", "15")
+	expiresAt := time.Now().Add(5 * time.Minute)
+	if err := StoreToken("tok", "rick", expiresAt, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline, err := ExtendTokenIdle("tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deadline.Equal(storedToken(t, "tok").ExpiresAt) {
+		t.Fatalf("nueva expiración = %v, no debe superar %v", deadline, expiresAt)
+	}
+}
+
+func TestExtendTokenIdleDisabled(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("TOKEN_IDLE_TIMEOUT_MINUTES", "")
+	if err := StoreToken("tok", "rick", time.Now().Add(time.Hour), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedToken(t, "tok"); stored.IdleExpiresAt != nil {
+		t.Fatalf("idle_expires_at = %v, se esperaba nil sin timeout", stored.IdleExpiresAt)
+	}
+
+	deadline, err := ExtendTokenIdle("tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deadline.Equal(storedToken(t, "tok").ExpiresAt) {
+		t.Fatalf("expiración = %v, se esperaba la absoluta", deadline)
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRateLimited indica que alguna ventana de uso del usuario está agotada
+var ErrRateLimited = errors.New("límite de peticiones alcanzado")
+
+// usageWindows son las ventanas fijas en las que se mide el uso, de la más
+// corta a la más larga. Se reinician en los límites UTC de minuto, hora y día.
+var usageWindows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"minute", time.Minute},
+	{"hour", time.Hour},
+	{"day", 24 * time.Hour},
+}
+
+// planRateLimits son las peticiones por minuto, hora y día de cada plan
+var planRateLimits = map[string][3]int{
+	"free":       {10, 100, 1000},
+	"pro":        {120, 5000, 50000},
+	"enterprise": {1000, 50000, 1000000},
+}
+
+// PlanRateLimits devuelve los límites por minuto, hora y día del plan. Se
+// pueden sobrescribir con RATE_LIMITS_<PLAN>=minuto,hora,día; 0 deshabilita
+// la ventana.
+func PlanRateLimits(plan string) [3]int {
+	limits, ok := planRateLimits[plan]
+	if !ok {
+		limits = planRateLimits["free"]
+	}
+	if override := os.Getenv("RATE_LIMITS_" + strings.ToUpper(plan)); override != "" {
+		parts := strings.Split(override, ",")
+		if len(parts) == len(limits) {
+			var parsed [3]int
+			valid := true
+			for i, part := range parts {
+				n, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil || n < 0 {
+					valid = false
+					break
+				}
+				parsed[i] = n
+			}
+			if valid {
+				return parsed
+			}
+		}
+	}
+	return limits
+}
+
+// WindowUsage es el estado de una ventana después de la petición
+type WindowUsage struct {
+	Window    string    `json:"window"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// UsageStatus describe el uso del usuario en todas sus ventanas. Limiting es
+// la ventana más restrictiva, la que se expone en las cabeceras X-RateLimit-*.
+type UsageStatus struct {
+	Windows  []WindowUsage `json:"windows"`
+	Limiting *WindowUsage  `json:"-"`
+}
+
+// RetryAfter es el tiempo hasta que la ventana limitante se reinicia
+func (s *UsageStatus) RetryAfter(now time.Time) time.Duration {
+	if s.Limiting == nil {
+		return 0
+	}
+	return s.Limiting.Reset.Sub(now)
+}
+
+// consumeUsage cuenta una petición en todas las ventanas del usuario dentro de
+// la transacción tx. Si alguna está agotada devuelve ErrRateLimited junto con
+// el estado, para que el cliente sepa cuándo reintentar; quien llama debe
+// deshacer la transacción para que no se cuente nada.
+//
+// Cada ventana se reinicia con un upsert y se incrementa con un UPDATE
+// condicionado al límite, así que dos peticiones simultáneas no pueden
+// perder un incremento ni superar el límite.
+func consumeUsage(tx *gorm.DB, username, plan string, now time.Time) (*UsageStatus, error) {
+	now = now.UTC()
+	limits := PlanRateLimits(plan)
+	status := &UsageStatus{}
+	limited := false
+	incremented := map[string]bool{}
+
+	for i, w := range usageWindows {
+		if limits[i] == 0 {
+			continue
+		}
+		start := now.Truncate(w.Duration)
+		// Crear la ventana o, si es de un período anterior, reiniciarla
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "username"}, {Name: "window"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"start": start, "count": 0}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "usage_windows.start <> excluded.start"}}},
+		}).Create(&models.UsageWindow{Username: username, Window: w.Name, Start: start}).Error
+		if err != nil {
+			return nil, err
+		}
+		result := tx.Model(&models.UsageWindow{}).
+			Where("username = ? AND window = ? AND count < ?", username, w.Name, limits[i]).
+			Update("count", gorm.Expr("count + 1"))
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			limited = true
+		} else {
+			incremented[w.Name] = true
+		}
+		status.Windows = append(status.Windows, WindowUsage{
+			Window: w.Name,
+			Limit:  limits[i],
+			Reset:  start.Add(w.Duration),
+		})
+	}
+
+	var stored []models.UsageWindow
+	if err := tx.Where("username = ?", username).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, window := range stored {
+		counts[window.Window] = window.Count
+	}
+	for i := range status.Windows {
+		w := &status.Windows[i]
+		count := counts[w.Window]
+		// Si la petición se rechaza, sus incrementos se deshacen
+		if limited && incremented[w.Window] {
+			count--
+		}
+		w.Remaining = w.Limit - count
+		if w.Remaining < 0 {
+			w.Remaining = 0
+		}
+	}
+
+	// La ventana limitante es la que tiene menos peticiones restantes; ante un
+	// empate, la que tarda más en reiniciarse
+	for i := range status.Windows {
+		w := &status.Windows[i]
+		if status.Limiting == nil || w.Remaining < status.Limiting.Remaining ||
+			(w.Remaining == status.Limiting.Remaining && w.Reset.After(status.Limiting.Reset)) {
+			status.Limiting = w
+		}
+	}
+	if limited {
+		return status, ErrRateLimited
+	}
+	return status, nil
+}
+
+// GetUsage devuelve el estado de las ventanas sin contar una petición
+func GetUsage(username, plan string, now time.Time) ([]WindowUsage, error) {
+	now = now.UTC()
+	limits := PlanRateLimits(plan)
+	var stored []models.UsageWindow
+	if err := DB.Where("username = ?", username).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	usage := []WindowUsage{}
+	for i, w := range usageWindows {
+		if limits[i] == 0 {
+			continue
+		}
+		start := now.Truncate(w.Duration)
+		count := 0
+		for _, s := range stored {
+			if s.Window == w.Name && s.Start.Equal(start) {
+				count = s.Count
+			}
+		}
+		remaining := limits[i] - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage = append(usage, WindowUsage{Window: w.Name, Limit: limits[i], Remaining: remaining, Reset: start.Add(w.Duration)})
+	}
+	return usage, nil
+}
+
+// RateLimitHeaders devuelve las cabeceras X-RateLimit-* de la ventana limitante
+func (s *UsageStatus) RateLimitHeaders() map[string]string {
+	if s.Limiting == nil {
+		return nil
+	}
+	return map[string]string{
+		"X-RateLimit-Limit":     strconv.Itoa(s.Limiting.Limit),
+		"X-RateLimit-Remaining": strconv.Itoa(s.Limiting.Remaining),
+		"X-RateLimit-Reset":     strconv.FormatInt(s.Limiting.Reset.Unix(), 10),
+		"X-RateLimit-Window":    s.Limiting.Window,
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsumeUsageWindows(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("RATE_LIMITS_FREE", "2,3,0")
+	createUsers(t, "rick")
+	if _, err := TopUp("rick", 100, "test"); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		at            time.Duration
+		wantErr       error
+		wantWindow    string
+		wantRemaining int
+	}{
+		{"primera petición", 0, nil, "minute", 1},
+		{"agota el minuto", 10 * time.Second, nil, "minute", 0},
+		{"minuto agotado", 20 * time.Second, ErrRateLimited, "minute", 0},
+		{"el minuto se reinicia", time.Minute, nil, "hour", 0},
+		{"la hora sigue agotada", 2 * time.Minute, ErrRateLimited, "hour", 0},
+		{"la hora se reinicia", time.Hour, nil, "minute", 1},
+	}
+	for _, tt := range tests {
+		charge, err := ChargeRequest("rick", "free", 0, "", base.Add(tt.at))
+		if !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: error = %v, se esperaba %v", tt.name, err, tt.wantErr)
+		}
+		limiting := charge.Usage.Limiting
+		if limiting.Window != tt.wantWindow || limiting.Remaining != tt.wantRemaining {
+			t.Fatalf("%s: ventana limitante %+v", tt.name, limiting)
+		}
+	}
+}
+
+func TestConsumeUsageConcurrent(t *testing.T) {
+	setupTestDB(t)
+	t.Setenv("RATE_LIMITS_FREE", "5,0,0")
+	createUsers(t, "rick")
+	if _, err := TopUp("rick", 100, "test"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted, limited := 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ChargeRequest("rick", "free", 0, "", now)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				accepted++
+			case errors.Is(err, ErrRateLimited):
+				limited++
+			default:
+				t.Errorf("error inesperado: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 5 || limited != 15 {
+		t.Fatalf("aceptadas %d, limitadas %d", accepted, limited)
+	}
+	assertDebits(t, "rick", 5)
+}
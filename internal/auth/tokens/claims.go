@@ -0,0 +1,140 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Valores por defecto de iss y aud cuando no se configuran
+const (
+	DefaultIssuer   = "api-ricky-and-morty-auth"
+	DefaultAudience = "api-ricky-and-morty"
+)
+
+// Actor identifica a quien actúa en nombre del sujeto (claim "act", RFC 8693)
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+// Claims son los claims de los tokens emitidos por el servicio de autenticación.
+// El sujeto (sub) es el nombre de usuario.
+type Claims struct {
+	jwt.RegisteredClaims
+	Role  string `json:"role"`
+	Plan  string `json:"plan"`
+	Org   int    `json:"org,omitempty"`
+	Actor *Actor `json:"act,omitempty"`
+	// Scope son los permisos del token separados por espacios (RFC 8693)
+	Scope string `json:"scope,omitempty"`
+}
+
+// Scopes devuelve los scopes del token como lista
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// ActorSubject devuelve quién actúa en nombre del usuario, o una cadena vacía
+// si el token no es de suplantación
+func (c *Claims) ActorSubject() string {
+	if c.Actor == nil {
+		return ""
+	}
+	return c.Actor.Subject
+}
+
+// Config contiene la clave y los parámetros de emisión y validación
+type Config struct {
+	Secret   []byte
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// ConfigFromEnv lee JWT_SECRET, JWT_ISSUER, JWT_AUDIENCE y JWT_LEEWAY_SECONDS
+func ConfigFromEnv() Config {
+	config := Config{
+		Secret:   []byte(os.Getenv("JWT_SECRET")),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+		Leeway:   30 * time.Second,
+	}
+	if config.Issuer == "" {
+		config.Issuer = DefaultIssuer
+	}
+	if config.Audience == "" {
+		config.Audience = DefaultAudience
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("JWT_LEEWAY_SECONDS")); err == nil && seconds >= 0 {
+		config.Leeway = time.Duration(seconds) * time.Second
+	}
+	return config
+}
+
+// NewClaims prepara los claims registrados de un token nuevo para el sujeto
+func (c Config) NewClaims(subject string, ttl time.Duration) (*Claims, error) {
+	return c.NewClaimsUntil(subject, time.Now().Add(ttl))
+}
+
+// NewClaimsUntil prepara los claims de un token que expira en expiresAt, por
+// ejemplo al renovar un token sin extender su límite absoluto
+func (c Config) NewClaimsUntil(subject string, expiresAt time.Time) (*Claims, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    c.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{c.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			// jti evita que dos tokens emitidos en el mismo segundo sean idénticos
+			ID: hex.EncodeToString(jti),
+		},
+	}, nil
+}
+
+// Sign firma los claims con HS256
+func (c Config) Sign(claims *Claims) (string, error) {
+	if len(c.Secret) == 0 {
+		return "", errors.New("JWT secret not set")
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(c.Secret)
+}
+
+// Parse verifica la firma y los claims registrados del token. Solo se acepta
+// HS256, y el emisor y la audiencia deben coincidir con la configuración, de
+// modo que un token de otro entorno se rechaza.
+func (c Config) Parse(tokenString string) (*Claims, error) {
+	if len(c.Secret) == 0 {
+		return nil, errors.New("JWT secret not set")
+	}
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return c.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(c.Issuer),
+		jwt.WithAudience(c.Audience),
+		jwt.WithLeeway(c.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, errors.New("token sin sub o jti")
+	}
+	return claims, nil
+}
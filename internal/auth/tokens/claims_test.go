@@ -0,0 +1,139 @@
+package tokens
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testConfig = Config{
+	Secret:   []byte("test-secret"),
+	Issuer:   DefaultIssuer,
+	Audience: DefaultAudience,
+	Leeway:   30 * time.Second,
+}
+
+func TestParse(t *testing.T) {
+	// sign firma los claims válidos tras aplicarles mutate
+	sign := func(method jwt.SigningMethod, key interface{}, mutate func(*Claims)) string {
+		claims, err := testConfig.NewClaims("rick", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims.Role, claims.Plan, claims.Scope = "user", "pro", "api:read api:write"
+		if mutate != nil {
+			mutate(claims)
+		}
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	hs256 := func(mutate func(*Claims)) string {
+		return sign(jwt.SigningMethodHS256, testConfig.Secret, mutate)
+	}
+	ago := func(d time.Duration) *jwt.NumericDate { return jwt.NewNumericDate(time.Now().Add(-d)) }
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "válido", token: hs256(nil)},
+		{name: "caducado dentro del margen", token: hs256(func(c *Claims) { c.ExpiresAt = ago(10 * time.Second) })},
+		{name: "caducado", token: hs256(func(c *Claims) { c.ExpiresAt = ago(time.Minute) }), wantErr: jwt.ErrTokenExpired},
+		{name: "sin exp", token: hs256(func(c *Claims) { c.ExpiresAt = nil }), wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "aún no válido", token: hs256(func(c *Claims) { c.NotBefore = ago(-time.Hour) }), wantErr: jwt.ErrTokenNotValidYet},
+		{name: "emitido en el futuro", token: hs256(func(c *Claims) { c.IssuedAt = ago(-time.Hour) }), wantErr: jwt.ErrTokenUsedBeforeIssued},
+		{name: "otro emisor", token: hs256(func(c *Claims) { c.Issuer = "otro-entorno" }), wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "otra audiencia", token: hs256(func(c *Claims) { c.Audience = jwt.ClaimStrings{"otra-api"} }), wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "otra clave", token: sign(jwt.SigningMethodHS256, []byte("otra"), nil), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "HS512", token: sign(jwt.SigningMethodHS512, testConfig.Secret, nil), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "alg none", token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, nil), anyErr: true},
+		{name: "sin sub", token: hs256(func(c *Claims) { c.Subject = "" }), anyErr: true},
+		{name: "sin jti", token: hs256(func(c *Claims) { c.ID = "" }), anyErr: true},
+		{name: "mal formado", token: "no.es.un-jwt", wantErr: jwt.ErrTokenMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := testConfig.Parse(tt.token)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("se esperaba un error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("error inesperado: %v", err)
+				}
+				if claims.Subject != "rick" || claims.Role != "user" || claims.Plan != "pro" {
+					t.Fatalf("claims = %+v", claims)
+				}
+			}
+		})
+	}
+}
+
+func TestSignAndParseRoundTrip(t *testing.T) {
+	claims, err := testConfig.NewClaims("morty", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims.Org = 7
+	claims.Scope = "api:read  admin"
+	claims.Actor = &Actor{Subject: "support"}
+	token, err := testConfig.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := testConfig.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Org != 7 || parsed.ActorSubject() != "support" || parsed.ID != claims.ID {
+		t.Fatalf("claims = %+v", parsed)
+	}
+	if scopes := parsed.Scopes(); len(scopes) != 2 || scopes[0] != "api:read" || scopes[1] != "admin" {
+		t.Fatalf("scopes = %v", scopes)
+	}
+
+	other, _ := testConfig.NewClaims("morty", time.Hour)
+	if other.ID == claims.ID {
+		t.Fatal("dos tokens con el mismo jti")
+	}
+	if (&Claims{}).ActorSubject() != "" {
+		t.Fatal("un token sin act no tiene actor")
+	}
+}
+
+func TestEmptySecret(t *testing.T) {
+	config := testConfig
+	config.Secret = nil
+	claims, _ := config.NewClaims("rick", time.Hour)
+	if _, err := config.Sign(claims); err == nil {
+		t.Fatal("se firmó sin clave")
+	}
+	token, _ := testConfig.Sign(claims)
+	if _, err := config.Parse(token); err == nil {
+		t.Fatal("se validó sin clave")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("JWT_SECRET", "s")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCE", "mi-api")
+	t.Setenv("JWT_LEEWAY_SECONDS", "5")
+	config := ConfigFromEnv()
+	if string(config.Secret) != "s" || config.Issuer != DefaultIssuer || config.Audience != "mi-api" || config.Leeway != 5*time.Second {
+		t.Fatalf("config = %+v", config)
+	}
+}
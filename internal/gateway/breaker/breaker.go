@@ -0,0 +1,246 @@
+// Package breaker implementa circuit breakers por upstream. Un breaker cerrado
+// registra el resultado de las últimas peticiones; si la tasa de fallos supera
+// el umbral se abre y rechaza las peticiones sin intentarlas. Pasado un tiempo
+// pasa a semiabierto y deja pasar unas pocas peticiones de prueba: si todas
+// salen bien se cierra y si alguna falla vuelve a abrirse.
+package breaker
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrOpen indica que el breaker está abierto y la petición no se intenta
+var ErrOpen = errors.New("circuit breaker abierto")
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half_open"
+)
+
+// Outcome es el resultado de una petición autorizada por el breaker
+type Outcome int
+
+const (
+	Success Outcome = iota
+	Failure
+	// Ignore no cuenta la petición, por ejemplo si el cliente la canceló
+	Ignore
+)
+
+// Config define los umbrales de un breaker
+type Config struct {
+	// Window es el número de peticiones recientes sobre las que se calcula la tasa de fallos
+	Window int
+	// MinRequests es el mínimo de peticiones en la ventana para poder abrirse
+	MinRequests int
+	// FailureRate es la fracción de fallos (0-1) a partir de la cual se abre
+	FailureRate float64
+	// OpenTimeout es el tiempo que permanece abierto antes de pasar a semiabierto
+	OpenTimeout time.Duration
+	// HalfOpenRequests es el número de peticiones de prueba en semiabierto
+	HalfOpenRequests int
+}
+
+// Breaker protege las llamadas a un upstream
+type Breaker struct {
+	name   string
+	config Config
+
+	mu    sync.Mutex
+	state State
+	// results es un buffer circular con los últimos resultados (true = fallo)
+	results  []bool
+	next     int
+	recorded int
+	failures int
+	openedAt time.Time
+	// Peticiones de prueba en curso y exitosas en semiabierto
+	probes    int
+	successes int
+	// generation cambia con cada transición de estado, para descartar los
+	// resultados de peticiones autorizadas en un estado anterior
+	generation int
+}
+
+func New(name string, config Config) *Breaker {
+	return &Breaker{name: name, config: config, state: StateClosed, results: make([]bool, config.Window)}
+}
+
+// Allow decide si se intenta la petición. Si se autoriza, la función devuelta
+// debe llamarse exactamente una vez con el resultado.
+func (b *Breaker) Allow() (func(Outcome), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.currentState(time.Now()) {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.config.HalfOpenRequests {
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+	generation := b.generation
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() { b.record(outcome, generation) })
+	}, nil
+}
+
+// currentState pasa de abierto a semiabierto cuando vence OpenTimeout
+func (b *Breaker) currentState(now time.Time) State {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.state = StateHalfOpen
+		b.probes = 0
+		b.successes = 0
+		b.generation++
+	}
+	return b.state
+}
+
+func (b *Breaker) record(outcome Outcome, generation int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case StateHalfOpen:
+		b.probes--
+		switch outcome {
+		case Failure:
+			b.open(time.Now())
+		case Success:
+			b.successes++
+			if b.successes >= b.config.HalfOpenRequests {
+				b.close()
+			}
+		}
+	case StateClosed:
+		if outcome == Ignore {
+			return
+		}
+		failed := outcome == Failure
+		if b.recorded == len(b.results) {
+			// Se sobrescribe el resultado más antiguo
+			if b.results[b.next] {
+				b.failures--
+			}
+		} else {
+			b.recorded++
+		}
+		b.results[b.next] = failed
+		b.next = (b.next + 1) % len(b.results)
+		if failed {
+			b.failures++
+		}
+		if b.recorded >= b.config.MinRequests && b.failureRate() >= b.config.FailureRate {
+			b.open(time.Now())
+		}
+	}
+}
+
+func (b *Breaker) failureRate() float64 {
+	if b.recorded == 0 {
+		return 0
+	}
+	return float64(b.failures) / float64(b.recorded)
+}
+
+func (b *Breaker) open(now time.Time) {
+	b.state = StateOpen
+	b.openedAt = now
+	b.generation++
+}
+
+func (b *Breaker) close() {
+	b.state = StateClosed
+	b.next, b.recorded, b.failures = 0, 0, 0
+	b.generation++
+}
+
+// RetryAfter es el tiempo que falta para que un breaker abierto admita
+// peticiones de prueba
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateOpen {
+		return 0
+	}
+	return time.Until(b.openedAt.Add(b.config.OpenTimeout))
+}
+
+// Snapshot describe el estado de un breaker para el endpoint de administración
+type Snapshot struct {
+	Name        string     `json:"name"`
+	State       State      `json:"state"`
+	Requests    int        `json:"requests"`
+	Failures    int        `json:"failures"`
+	FailureRate float64    `json:"failure_rate"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"`
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snapshot := Snapshot{
+		Name:        b.name,
+		State:       b.currentState(time.Now()),
+		Requests:    b.recorded,
+		Failures:    b.failures,
+		FailureRate: b.failureRate(),
+	}
+	if snapshot.State != StateClosed {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(b.config.OpenTimeout)
+		snapshot.OpenedAt = &openedAt
+		snapshot.RetryAt = &retryAt
+	}
+	return snapshot
+}
+
+// Set agrupa un breaker por upstream, creados bajo demanda con la misma configuración
+type Set struct {
+	config   Config
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+func NewSet(config Config) *Set {
+	return &Set{config: config, breakers: map[string]*Breaker{}}
+}
+
+// Get devuelve el breaker del upstream
+func (s *Set) Get(name string) *Breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[name]
+	if !ok {
+		b = New(name, s.config)
+		s.breakers[name] = b
+	}
+	return b
+}
+
+// Snapshots devuelve el estado de todos los breakers ordenados por nombre
+func (s *Set) Snapshots() []Snapshot {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.breakers))
+	for name := range s.breakers {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+	snapshots := make([]Snapshot, 0, len(names))
+	for _, name := range names {
+		snapshots = append(snapshots, s.Get(name).Snapshot())
+	}
+	return snapshots
+}
@@ -0,0 +1,116 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const testOpenTimeout = 20 * time.Millisecond
+
+var testConfig = Config{Window: 4, MinRequests: 4, FailureRate: 0.5, OpenTimeout: testOpenTimeout, HalfOpenRequests: 2}
+
+func TestBreakerStateMachine(t *testing.T) {
+	// Cada paso es "ok", "fail" o "ignore" (una petición con ese resultado),
+	// "rejected" (Allow debe devolver ErrOpen) o "wait" (vence OpenTimeout)
+	tests := []struct {
+		name  string
+		steps []string
+		want  State
+	}{
+		{"sigue cerrado bajo el mínimo", []string{"fail", "fail", "fail"}, StateClosed},
+		{"se abre al alcanzar la tasa", []string{"ok", "ok", "fail", "fail"}, StateOpen},
+		{"bajo el umbral sigue cerrado", []string{"ok", "ok", "ok", "fail"}, StateClosed},
+		{"la ventana olvida los fallos antiguos", []string{"fail", "ok", "ok", "ok", "ok", "fail"}, StateClosed},
+		{"las canceladas no cuentan", []string{"ok", "ignore", "ignore", "fail", "fail"}, StateClosed},
+		{"abierto rechaza", []string{"fail", "fail", "fail", "fail", "rejected"}, StateOpen},
+		{"pasa a semiabierto", []string{"fail", "fail", "fail", "fail", "wait"}, StateHalfOpen},
+		{"las pruebas correctas lo cierran", []string{"fail", "fail", "fail", "fail", "wait", "ok", "ok"}, StateClosed},
+		{"una prueba fallida lo reabre", []string{"fail", "fail", "fail", "fail", "wait", "ok", "fail", "rejected"}, StateOpen},
+		{"al cerrarse empieza con la ventana vacía", []string{"fail", "fail", "fail", "fail", "wait", "ok", "ok", "fail", "fail", "fail"}, StateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New("rickmorty", testConfig)
+			for i, step := range tt.steps {
+				if step == "wait" {
+					time.Sleep(testOpenTimeout)
+					continue
+				}
+				done, err := b.Allow()
+				if step == "rejected" {
+					if !errors.Is(err, ErrOpen) {
+						t.Fatalf("paso %d: error = %v, se esperaba ErrOpen", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("paso %d: %v", i, err)
+				}
+				done(map[string]Outcome{"ok": Success, "fail": Failure, "ignore": Ignore}[step])
+			}
+			if got := b.Snapshot().State; got != tt.want {
+				t.Fatalf("estado = %s, se esperaba %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenLimitsProbes(t *testing.T) {
+	b := New("rickmorty", testConfig)
+	for i := 0; i < 4; i++ {
+		done, _ := b.Allow()
+		done(Failure)
+	}
+	if b.RetryAfter() <= 0 {
+		t.Fatal("un breaker abierto debe indicar cuándo reintentar")
+	}
+	time.Sleep(testOpenTimeout)
+
+	var probes []func(Outcome)
+	for i := 0; i < testConfig.HalfOpenRequests; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatalf("prueba %d rechazada: %v", i, err)
+		}
+		probes = append(probes, done)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("se admitió una prueba de más: %v", err)
+	}
+	// Cancelar una prueba libera su hueco
+	probes[0](Ignore)
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("no se liberó el hueco de la prueba cancelada: %v", err)
+	}
+}
+
+func TestBreakerStaleOutcome(t *testing.T) {
+	b := New("rickmorty", testConfig)
+	stale, _ := b.Allow()
+	for i := 0; i < 4; i++ {
+		done, _ := b.Allow()
+		done(Failure)
+	}
+	time.Sleep(testOpenTimeout)
+	b.Allow()
+	// El resultado de una petición autorizada antes de abrirse no cuenta
+	// como prueba en semiabierto, y llamarlo dos veces no tiene efecto
+	stale(Failure)
+	stale(Failure)
+	if got := b.Snapshot().State; got != StateHalfOpen {
+		t.Fatalf("estado = %s", got)
+	}
+}
+
+func TestSetSnapshots(t *testing.T) {
+	s := NewSet(testConfig)
+	if s.Get("rickmorty") != s.Get("rickmorty") {
+		t.Fatal("Get debe devolver siempre el mismo breaker")
+	}
+	s.Get("auth")
+	snapshots := s.Snapshots()
+	if len(snapshots) != 2 || snapshots[0].Name != "auth" || snapshots[1].Name != "rickmorty" {
+		t.Fatalf("snapshots = %+v", snapshots)
+	}
+}
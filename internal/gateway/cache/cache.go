@@ -0,0 +1,168 @@
+// Package cache implementa la caché de respuestas HTTP del Gateway: un LRU en
+// memoria indexado por la URL normalizada y las cabeceras que nombre el Vary
+// de cada respuesta.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry es una respuesta guardada
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	ETag    string
+	Stored  time.Time
+	Expires time.Time
+}
+
+// Age es el tiempo que lleva la respuesta en la caché
+func (e *Entry) Age(now time.Time) time.Duration {
+	return now.Sub(e.Stored)
+}
+
+// Cache es un LRU de respuestas con un máximo de entradas
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List
+	entries    map[string]*list.Element
+	// varies guarda, por clave base, las cabeceras del Vary de la última
+	// respuesta, y variants cuántas variantes hay guardadas
+	varies   map[string][]string
+	variants map[string]int
+}
+
+type element struct {
+	key   string
+	base  string
+	entry *Entry
+}
+
+func New(maxEntries int) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		varies:     map[string][]string{},
+		variants:   map[string]int{},
+	}
+}
+
+// Key devuelve la clave base de una petición: el método y la URL con la ruta
+// limpia y los parámetros ordenados. scope separa las entradas por usuario en
+// las rutas privadas.
+func Key(r *http.Request, scope string) string {
+	cleaned := path.Clean("/" + r.URL.Path)
+	return strings.Join([]string{r.Method, cleaned + "?" + r.URL.Query().Encode(), scope}, "\x00")
+}
+
+// variantKey agrega a la clave base los valores de las cabeceras del Vary
+func variantKey(base string, vary []string, r *http.Request) string {
+	parts := []string{base}
+	for _, name := range vary {
+		parts = append(parts, name+"="+strings.Join(r.Header.Values(name), ","))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// Get devuelve la respuesta vigente para la petición
+func (c *Cache) Get(base string, r *http.Request, now time.Time) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vary, ok := c.varies[base]
+	if !ok {
+		return nil, false
+	}
+	elem, ok := c.entries[variantKey(base, vary, r)]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*element)
+	if now.After(e.entry.Expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return e.entry, true
+}
+
+// Put guarda la respuesta. Las respuestas con Vary: * no se pueden guardar.
+func (c *Cache) Put(base string, r *http.Request, entry *Entry) bool {
+	var vary []string
+	for _, value := range entry.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return false
+			}
+			if name != "" {
+				vary = append(vary, name)
+			}
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.varies[base] = vary
+	key := variantKey(base, vary, r)
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*element).entry = entry
+		c.lru.MoveToFront(elem)
+		return true
+	}
+	c.entries[key] = c.lru.PushFront(&element{key: key, base: base, entry: entry})
+	c.variants[base]++
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+	return true
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	e := elem.Value.(*element)
+	c.lru.Remove(elem)
+	delete(c.entries, e.key)
+	// Sin variantes para la clave base, su Vary deja de hacer falta
+	c.variants[e.base]--
+	if c.variants[e.base] <= 0 {
+		delete(c.variants, e.base)
+		delete(c.varies, e.base)
+	}
+}
+
+// Len devuelve el número de respuestas guardadas
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// ETag calcula un ETag fuerte a partir del cuerpo de la respuesta
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// MatchesETag compara If-None-Match con el ETag. If-None-Match usa la
+// comparación débil, así que se ignora el prefijo W/.
+func MatchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"/api/v1/characters?page=2&name=rick", "/api/v1/characters?name=rick&page=2", true},
+		{"/api/v1//characters/", "/api/v1/characters", true},
+		{"/api/v1/characters/../episodes", "/api/v1/episodes", true},
+		{"/api/v1/characters?page=1", "/api/v1/characters?page=2", false},
+	}
+	for _, tt := range tests {
+		a := Key(httptest.NewRequest("GET", tt.a, nil), "")
+		b := Key(httptest.NewRequest("GET", tt.b, nil), "")
+		if (a == b) != tt.equal {
+			t.Errorf("Key(%q) == Key(%q) es %v", tt.a, tt.b, a == b)
+		}
+	}
+	r := httptest.NewRequest("GET", "/api/v1/me", nil)
+	if Key(r, "user:rick") == Key(r, "user:morty") {
+		t.Error("las claves de usuarios distintos coinciden")
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	c := New(10)
+	now := time.Now()
+	es := httptest.NewRequest("GET", "/api/v1/characters", nil)
+	es.Header.Set("Accept-Language", "es")
+	en := httptest.NewRequest("GET", "/api/v1/characters", nil)
+	en.Header.Set("Accept-Language", "en")
+	base := Key(es, "")
+
+	header := http.Header{"Vary": {"accept-language"}}
+	c.Put(base, es, &Entry{Status: 200, Header: header, Body: []byte("hola"), Expires: now.Add(time.Minute)})
+	if _, ok := c.Get(base, en, now); ok {
+		t.Fatal("se sirvió la variante de otro idioma")
+	}
+	c.Put(base, en, &Entry{Status: 200, Header: header, Body: []byte("hello"), Expires: now.Add(time.Minute)})
+	for r, want := range map[*http.Request]string{es: "hola", en: "hello"} {
+		entry, ok := c.Get(base, r, now)
+		if !ok || string(entry.Body) != want {
+			t.Fatalf("variante %s: %v", r.Header.Get("Accept-Language"), entry)
+		}
+	}
+	if c.Put(base, es, &Entry{Header: http.Header{"Vary": {"*"}}}) {
+		t.Fatal("se guardó una respuesta con Vary: *")
+	}
+}
+
+func TestCacheExpiryAndEviction(t *testing.T) {
+	c := New(2)
+	now := time.Now()
+	requests := make([]*http.Request, 3)
+	for i, target := range []string{"/a", "/b", "/c"} {
+		requests[i] = httptest.NewRequest("GET", target, nil)
+	}
+	put := func(i int, ttl time.Duration) {
+		c.Put(Key(requests[i], ""), requests[i], &Entry{Status: 200, Header: http.Header{}, Stored: now, Expires: now.Add(ttl)})
+	}
+	get := func(i int, at time.Time) bool {
+		_, ok := c.Get(Key(requests[i], ""), requests[i], at)
+		return ok
+	}
+
+	put(0, time.Minute)
+	put(1, time.Minute)
+	get(0, now) // /a pasa a ser la más reciente
+	put(2, time.Minute)
+	if get(1, now) || !get(0, now) || !get(2, now) {
+		t.Fatal("no se desalojó la entrada menos usada")
+	}
+	if get(0, now.Add(2*time.Minute)) || c.Len() != 1 {
+		t.Fatalf("la entrada caducada sigue en la caché (%d entradas)", c.Len())
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	etag := ETag([]byte("body"))
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{etag, true},
+		{"W/" + etag, true},
+		{`"otro", ` + etag, true},
+		{"*", true},
+		{`"otro"`, false},
+	}
+	for _, tt := range tests {
+		if got := MatchesETag(tt.ifNoneMatch, etag); got != tt.want {
+			t.Errorf("MatchesETag(%q) = %v", tt.ifNoneMatch, got)
+		}
+	}
+	if ETag([]byte("body")) != etag || ETag([]byte("otro")) == etag {
+		t.Error("el ETag no depende solo del cuerpo")
+	}
+}
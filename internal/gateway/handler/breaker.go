@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/breaker"
+)
+
+// authUpstream es el nombre del breaker de las validaciones de token. Coincide
+// con el upstream "auth" de la tabla de rutas para que ambos compartan estado.
+const authUpstream = "auth"
+
+// breakerConfig lee la configuración de los circuit breakers:
+// GATEWAY_BREAKER_WINDOW (20 peticiones), GATEWAY_BREAKER_MIN_REQUESTS (10),
+// GATEWAY_BREAKER_FAILURE_RATE (50 %), GATEWAY_BREAKER_OPEN_SECONDS (30) y
+// GATEWAY_BREAKER_HALF_OPEN_REQUESTS (3)
+func breakerConfig() breaker.Config {
+	config := breaker.Config{
+		Window:           envInt("GATEWAY_BREAKER_WINDOW", 20),
+		MinRequests:      envInt("GATEWAY_BREAKER_MIN_REQUESTS", 10),
+		FailureRate:      float64(envInt("GATEWAY_BREAKER_FAILURE_RATE", 50)) / 100,
+		OpenTimeout:      envSeconds("GATEWAY_BREAKER_OPEN_SECONDS", 30*time.Second),
+		HalfOpenRequests: envInt("GATEWAY_BREAKER_HALF_OPEN_REQUESTS", 3),
+	}
+	if config.MinRequests > config.Window {
+		config.MinRequests = config.Window
+	}
+	return config
+}
+
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// sendCircuitOpen responde sin intentar la petición mientras el breaker del
+// upstream está abierto
+func sendCircuitOpen(w http.ResponseWriter, upstream string, b *breaker.Breaker) {
+	retryAfter := ceilSeconds(b.RetryAfter())
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	sendJSONResponse(w, http.StatusServiceUnavailable, "error", "El servicio "+upstream+" no está disponible temporalmente", map[string]interface{}{
+		"error_code":  "upstream_circuit_open",
+		"upstream":    upstream,
+		"retry_after": retryAfter,
+	})
+}
+
+// upstreamOutcome clasifica una respuesta para el breaker: los errores del
+// servidor cuentan como fallo y el resto como éxito
+func upstreamOutcome(statusCode int) breaker.Outcome {
+	if statusCode >= http.StatusInternalServerError {
+		return breaker.Failure
+	}
+	return breaker.Success
+}
+
+// BreakersHandler devuelve el estado de los circuit breakers. Requiere un
+// token con el scope admin.
+func (h *GatewayHandler) BreakersHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.validateToken(w, r)
+	if !ok {
+		return
+	}
+	// La consulta no llega a ningún upstream: no se cobra
+	go h.refundDebit(info.DebitID, "Endpoint de administración del Gateway")
+	if missing := missingScopes([]string{"admin"}, info.Scopes); len(missing) > 0 {
+		sendJSONResponse(w, http.StatusForbidden, "error", "El token no tiene los scopes requeridos", map[string]interface{}{
+			"error_code":      "insufficient_scope",
+			"required_scopes": missing,
+		})
+		return
+	}
+	sendJSONResponse(w, http.StatusOK, "success", "Estado de los circuit breakers", h.breakers.Snapshots())
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/cache"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/routes"
+)
+
+// maxCachedBody es el tamaño máximo de una respuesta que se guarda en la
+// caché (GATEWAY_CACHE_MAX_BODY_KB, por defecto 1024). Las mayores se
+// transmiten en streaming sin guardarse.
+func maxCachedBody() int64 {
+	return int64(envInt("GATEWAY_CACHE_MAX_BODY_KB", 1024)) << 10
+}
+
+// cacheableRoute indica si las respuestas de la ruta se guardan en la caché
+func cacheableRoute(r *http.Request, route routes.Route) bool {
+	return r.Method == http.MethodGet && route.Cache != nil && route.Cache.TTL.Duration > 0
+}
+
+// cacheScope separa la caché por usuario en las rutas privadas, cuyas
+// respuestas dependen de quién las pide
+func cacheScope(route routes.Route, info *tokenInfo) string {
+	if route.Cache.Private {
+		return "user:" + info.Username
+	}
+	return ""
+}
+
+// responseCacheControl devuelve el Cache-Control de una respuesta. La
+// política de la ruta solo se aplica a los 200 (y a los 304 que los
+// revalidan) sin Set-Cookie; el resto lleva no-store para que nadie guarde
+// errores. Si la ruta exige credenciales la respuesta es private aunque la
+// política sea pública: una caché compartida la serviría sin pasar por el
+// Gateway.
+func responseCacheControl(route routes.Route, status int, header http.Header) string {
+	if (status != http.StatusOK && status != http.StatusNotModified) || len(header.Values("Set-Cookie")) > 0 {
+		return "no-store"
+	}
+	policy := route.Cache
+	if policy != nil && !policy.Private && (route.RequiresAuth() || route.PassCredentials) {
+		private := *policy
+		private.Private = true
+		policy = &private
+	}
+	return policy.CacheControl()
+}
+
+// clientCacheDirectives lee las directivas del cliente: no-cache obliga a ir
+// al upstream y no-store además impide guardar la respuesta
+func clientCacheDirectives(r *http.Request) (noCache, noStore bool) {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "max-age=0":
+			noCache = true
+		case "no-store":
+			noCache, noStore = true, true
+		}
+	}
+	if r.Header.Get("Pragma") == "no-cache" {
+		noCache = true
+	}
+	return noCache, noStore
+}
+
+// serveFromCache responde desde la caché si hay una respuesta vigente. Si no,
+// devuelve la clave con la que guardar la respuesta del upstream, o vacía si
+// no se debe guardar.
+func (h *GatewayHandler) serveFromCache(w http.ResponseWriter, r *http.Request, route routes.Route, info *tokenInfo) (string, bool) {
+	if !cacheableRoute(r, route) {
+		return "", false
+	}
+	noCache, noStore := clientCacheDirectives(r)
+	if noStore {
+		cacheRequests.Inc("responses", "bypass")
+		w.Header().Set("X-Cache", "BYPASS")
+		return "", false
+	}
+	key := cache.Key(r, cacheScope(route, info))
+	if noCache {
+		cacheRequests.Inc("responses", "bypass")
+		return key, false
+	}
+	now := time.Now()
+	entry, ok := h.responses.Get(key, r, now)
+	if !ok {
+		cacheRequests.Inc("responses", "miss")
+		return key, false
+	}
+	cacheRequests.Inc("responses", "hit")
+
+	header := w.Header()
+	for name, values := range entry.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Cache-Control", responseCacheControl(route, entry.Status, entry.Header))
+	header.Set("Age", strconv.Itoa(int(entry.Age(now).Seconds())))
+	header.Set("X-Cache", "HIT")
+	if cache.MatchesETag(r.Header.Get("If-None-Match"), entry.ETag) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return key, true
+	}
+	w.WriteHeader(entry.Status)
+	w.Write(entry.Body)
+	return key, true
+}
+
+// captureResponse lee la respuesta del upstream para calcular su ETag y
+// guardarla en la caché. Si el cliente ya tiene esa versión, la convierte en
+// un 304.
+func (h *GatewayHandler) captureResponse(resp *http.Response, data *proxyRequestInfo) error {
+	resp.Header.Set("X-Cache", "MISS")
+	if resp.StatusCode != http.StatusOK || len(resp.Header.Values("Set-Cookie")) > 0 {
+		return nil
+	}
+	limit := maxCachedBody()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > limit {
+		// Demasiado grande: se envía lo leído seguido del resto sin guardarlo
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	etag := cache.ETag(body)
+	resp.Header.Set("ETag", etag)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	now := time.Now()
+	header := resp.Header.Clone()
+	header.Del("X-Cache")
+	header.Del("Date")
+	h.responses.Put(data.cacheKey, data.in, &cache.Entry{
+		Status:  resp.StatusCode,
+		Header:  header,
+		Body:    body,
+		ETag:    etag,
+		Stored:  now,
+		Expires: now.Add(data.route.Cache.TTL.Duration),
+	})
+
+	if cache.MatchesETag(data.ifNoneMatch, etag) {
+		resp.StatusCode = http.StatusNotModified
+		resp.Status = "304 Not Modified"
+		resp.Header.Del("Content-Length")
+		resp.Header.Del("Content-Type")
+		resp.ContentLength = 0
+		resp.Body = http.NoBody
+	}
+	return nil
+}
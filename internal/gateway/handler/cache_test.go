@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/cache"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/routes"
+)
+
+func TestResponseCacheControl(t *testing.T) {
+	public := false
+	minute := &routes.CachePolicy{TTL: routes.Duration{Duration: time.Minute}}
+	private := &routes.CachePolicy{TTL: routes.Duration{Duration: time.Minute}, Private: true}
+	cookie := http.Header{"Set-Cookie": {"session=abc"}}
+
+	tests := []struct {
+		name   string
+		route  routes.Route
+		status int
+		header http.Header
+		want   string
+	}{
+		{"ruta pública", routes.Route{Auth: &public, Cache: minute}, 200, nil, "public, max-age=60"},
+		{"revalidación", routes.Route{Auth: &public, Cache: minute}, 304, nil, "public, max-age=60"},
+		{"ruta con token", routes.Route{Cache: minute}, 200, nil, "private, max-age=60"},
+		{"reenvía credenciales", routes.Route{Auth: &public, PassCredentials: true, Cache: minute}, 200, nil, "private, max-age=60"},
+		{"ruta privada", routes.Route{Cache: private}, 200, nil, "private, max-age=60"},
+		{"error del cliente", routes.Route{Auth: &public, Cache: minute}, 404, nil, "no-store"},
+		{"error del upstream", routes.Route{Auth: &public, Cache: minute}, 503, nil, "no-store"},
+		{"con Set-Cookie", routes.Route{Auth: &public, Cache: minute}, 200, cookie, "no-store"},
+		{"sin política", routes.Route{Auth: &public}, 200, nil, "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			if got := responseCacheControl(tt.route, tt.status, header); got != tt.want {
+				t.Fatalf("Cache-Control = %q, se esperaba %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureAndServeFromCache(t *testing.T) {
+	h := &GatewayHandler{responses: cache.New(10)}
+	route := routes.Route{Cache: &routes.CachePolicy{TTL: routes.Duration{Duration: time.Minute}}}
+	info := &tokenInfo{Username: "rick"}
+	in := httptest.NewRequest("GET", "/api/v1/characters?page=2", nil)
+
+	key, served := h.serveFromCache(httptest.NewRecorder(), in, route, info)
+	if served || key == "" {
+		t.Fatalf("primera petición servida desde la caché: %q", key)
+	}
+	upstream := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"results":[]}`)),
+	}
+	if err := h.captureResponse(upstream, &proxyRequestInfo{route: route, cacheKey: key, in: in}); err != nil {
+		t.Fatal(err)
+	}
+	etag := upstream.Header.Get("ETag")
+	if etag != cache.ETag([]byte(`{"results":[]}`)) || upstream.Header.Get("X-Cache") != "MISS" {
+		t.Fatalf("cabeceras del MISS: %v", upstream.Header)
+	}
+
+	w := httptest.NewRecorder()
+	if _, served := h.serveFromCache(w, in, route, info); !served {
+		t.Fatal("la respuesta no se guardó")
+	}
+	if w.Code != http.StatusOK || w.Body.String() != `{"results":[]}` || w.Header().Get("X-Cache") != "HIT" ||
+		w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("HIT: %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	conditional := httptest.NewRequest("GET", "/api/v1/characters?page=2", nil)
+	conditional.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.serveFromCache(w, conditional, route, info)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("If-None-Match: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCaptureResponseSkipsErrors(t *testing.T) {
+	h := &GatewayHandler{responses: cache.New(10)}
+	route := routes.Route{Cache: &routes.CachePolicy{TTL: routes.Duration{Duration: time.Minute}}}
+	in := httptest.NewRequest("GET", "/api/v1/characters", nil)
+	for _, resp := range []*http.Response{
+		{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: http.NoBody},
+		{StatusCode: http.StatusOK, Header: http.Header{"Set-Cookie": {"session=abc"}}, Body: http.NoBody},
+	} {
+		if err := h.captureResponse(resp, &proxyRequestInfo{route: route, cacheKey: cache.Key(in, ""), in: in}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h.responses.Len() != 0 {
+		t.Fatalf("se guardaron %d respuestas", h.responses.Len())
+	}
+}
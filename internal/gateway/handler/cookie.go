@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+)
+
+// cookieSecure indica si las cookies se marcan Secure: el cliente llegó por
+// HTTPS o el Gateway está detrás de un balanceador que termina TLS
+func cookieSecure(r *http.Request) bool {
+	return r.TLS != nil || os.Getenv("GATEWAY_COOKIE_SECURE") == "true"
+}
+
+// gatewayCookie aplica los atributos del origen del Gateway a una cookie:
+// dominio GATEWAY_COOKIE_DOMAIN (o ninguno), Path / si no trae, Secure y
+// SameSite=Lax si no trae otro. Lo usan tanto las cookies que reescribe el
+// proxy como las que renueva el propio Gateway, para que coincidan siempre.
+func gatewayCookie(cookie *http.Cookie, secure bool) *http.Cookie {
+	cookie.Domain = os.Getenv("GATEWAY_COOKIE_DOMAIN")
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if secure {
+		cookie.Secure = true
+	}
+	// El valor cero significa que la cookie no trae SameSite
+	if cookie.SameSite == 0 || cookie.SameSite == http.SameSiteDefaultMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	return cookie
+}
+
+// rewriteSetCookies adapta las cookies del upstream al origen del Gateway
+func rewriteSetCookies(header http.Header, secure bool) {
+	cookies := (&http.Response{Header: header}).Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		header.Add("Set-Cookie", gatewayCookie(cookie, secure).String())
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGatewayCookieMatchesRewrittenCookie(t *testing.T) {
+	t.Setenv("GATEWAY_COOKIE_DOMAIN", "api.example.org")
+	// Cookie emitida por el servicio de autenticación, tal como llega al proxy
+	header := http.Header{}
+	header.Add("Set-Cookie", "auth_token=abc; Path=/; Domain=auth; HttpOnly")
+	rewriteSetCookies(header, true)
+	rewritten := (&http.Response{Header: header}).Cookies()[0]
+
+	w := httptest.NewRecorder()
+	http.SetCookie(w, gatewayCookie(&http.Cookie{
+		Name: "auth_token", Value: "abc", HttpOnly: true, Expires: time.Now().Add(time.Hour),
+	}, true))
+	renewed := w.Result().Cookies()[0]
+
+	for _, c := range []*http.Cookie{rewritten, renewed} {
+		if c.Domain != "api.example.org" || c.Path != "/" || !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie %s con atributos inesperados: %s", c.Name, c.String())
+		}
+	}
+}
+
+func TestRewriteSetCookiesKeepsSameSite(t *testing.T) {
+	t.Setenv("GATEWAY_COOKIE_DOMAIN", "")
+	header := http.Header{}
+	header.Add("Set-Cookie", "prefs=1; Domain=auth; SameSite=Strict")
+	rewriteSetCookies(header, false)
+	got := header.Get("Set-Cookie")
+	if !strings.Contains(got, "SameSite=Strict") || strings.Contains(got, "Domain") || strings.Contains(got, "Secure") {
+		t.Fatalf("Set-Cookie = %q", got)
+	}
+}
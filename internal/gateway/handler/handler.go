@@ -1,14 +1,28 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/api_ricky_and_morty/internal/auth/tokens"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/breaker"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/cache"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/ratelimit"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/retry"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/routes"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
 )
 
 type Response struct {
@@ -18,19 +32,55 @@ type Response struct {
 }
 
 type GatewayHandler struct {
-	client        *http.Client
-	authPort      string
-	rickMortyPort string
+	client   *http.Client
+	authPort string
+	// internalSecret autentica al Gateway en los endpoints /internal del
+	// servicio de autenticación (INTERNAL_AUTH_SECRET)
+	internalSecret string
+	routes         *routes.Table
+	// proxies tiene un proxy inverso por cada upstream de la tabla de rutas
+	proxies map[string]*httputil.ReverseProxy
+	// upstreamTransport es el transporte con reintentos de los proxies
+	upstreamTransport http.RoundTripper
+	// Verificación local de tokens y caché de validaciones. Sin JWT_SECRET
+	// todas las peticiones se validan con el servicio de autenticación.
+	tokenConfig tokens.Config
+	cache       *validationCache
+	usage       *usageBatcher
+	asyncPlans  map[string]bool
+	// limiter guarda los contadores de los límites de tasa por ruta
+	limiter ratelimit.Store
+	// breakers tiene un circuit breaker por upstream
+	breakers *breaker.Set
+	// responses es la caché de respuestas de las rutas con cache
+	responses *cache.Cache
 }
 
-func NewGatewayHandler(authPort, rickMortyPort string) *GatewayHandler {
-	return &GatewayHandler{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		authPort:      authPort,
-		rickMortyPort: rickMortyPort,
+func NewGatewayHandler(authPort string, table *routes.Table) *GatewayHandler {
+	h := &GatewayHandler{
+		// Validación, reembolsos y uso cobran o modifican créditos: nunca se
+		// reintentan, para no cobrar dos veces una misma petición
+		client: &http.Client{Timeout: 10 * time.Second},
+		// Las peticiones GET reenviadas a los upstreams se reintentan ante fallos transitorios
+		upstreamTransport: retry.NewTransport(http.DefaultTransport, retryPolicy()),
+		authPort:          authPort,
+		internalSecret:    os.Getenv("INTERNAL_AUTH_SECRET"),
+		routes:            table,
+		proxies:           map[string]*httputil.ReverseProxy{},
+		tokenConfig:       tokens.ConfigFromEnv(),
+		cache:             newValidationCache(envSeconds("GATEWAY_VALIDATION_CACHE_SECONDS", 10*time.Second)),
+		usage:             newUsageBatcher(),
+		asyncPlans:        asyncUsagePlans(),
+		limiter:           ratelimit.NewMemoryStore(),
+		breakers:          breaker.NewSet(breakerConfig()),
+		responses:         cache.New(envInt("GATEWAY_CACHE_MAX_ENTRIES", 1000)),
+	}
+	for name, base := range table.Upstreams {
+		target, _ := url.Parse(base)
+		h.proxies[name] = h.newUpstreamProxy(target)
 	}
+	h.startUsageFlusher(envSeconds("GATEWAY_USAGE_FLUSH_SECONDS", 2*time.Second))
+	return h
 }
 
 func sendJSONResponse(w http.ResponseWriter, statusCode int, status, message string, data interface{}) {
@@ -173,137 +223,250 @@ func RickMortyHandler(w http.ResponseWriter, r *http.Request) {
 	sendJSONResponse(w, http.StatusOK, "success", "Datos obtenidos exitosamente", data)
 }
 
-func (h *GatewayHandler) proxyToRickMorty(w http.ResponseWriter, r *http.Request) {
-	// Crear una nueva petición al servicio Rick and Morty
-	rickMortyURL := fmt.Sprintf("http://rickmorty:%s%s", h.rickMortyPort, r.URL.Path)
-	req, err := http.NewRequest(r.Method, rickMortyURL, nil)
-	if err != nil {
-		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error al crear la petición", nil)
-		return
+// Router construye el router con las rutas de la tabla
+func (h *GatewayHandler) Router() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/admin/breakers", h.BreakersHandler).Methods("GET")
+	for _, route := range h.routes.Routes {
+		router.HandleFunc(route.Path, h.routeHandler(route)).Methods(route.Methods...)
 	}
+	return router
+}
 
-	// Copiar los query parameters
-	req.URL.RawQuery = r.URL.RawQuery
-
-	// Agregar el header de autenticación interna
-	req.Header.Set("X-Internal-Auth", "gateway-service")
-
-	// Realizar la petición
-	resp, err := h.client.Do(req)
-	if err != nil {
-		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error al comunicarse con el servicio Rick and Morty", nil)
-		return
+// routeHandler valida el token y los scopes que exige la ruta y reenvía la
+// petición a su upstream
+func (h *GatewayHandler) routeHandler(route routes.Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.checkRateLimit(w, r, route) {
+			return
+		}
+		info := &tokenInfo{}
+		if route.RequiresAuth() {
+			var ok bool
+			if info, ok = h.validateToken(w, r); !ok {
+				return
+			}
+			middleware.SetUser(r.Context(), info.Username)
+			if missing := missingScopes(route.Scopes, info.Scopes); len(missing) > 0 {
+				go h.refundDebit(info.DebitID, "Scopes insuficientes")
+				sendJSONResponse(w, http.StatusForbidden, "error", "El token no tiene los scopes requeridos", map[string]interface{}{
+					"error_code":      "insufficient_scope",
+					"required_scopes": missing,
+				})
+				return
+			}
+		}
+		// Las rutas que reciben las credenciales pueden revocar el token, como
+		// /logout: su validación en caché deja de servir
+		if route.PassCredentials {
+			if token, ok := requestToken(r); ok {
+				h.cache.evictToken(token)
+			}
+		}
+		h.proxyRequest(w, r, info, route)
 	}
-	defer resp.Body.Close()
+}
 
-	// Leer la respuesta
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error al leer la respuesta", nil)
-		return
+// requestToken obtiene el token de la cookie o del header Authorization
+func requestToken(r *http.Request) (string, bool) {
+	if cookie, err := r.Cookie("auth_token"); err == nil && cookie.Value != "" {
+		return cookie.Value, true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		return token, true
 	}
+	return "", false
+}
 
-	// Copiar los headers de la respuesta
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
+// missingScopes devuelve los scopes requeridos que el token no tiene
+func missingScopes(required, granted []string) []string {
+	var missing []string
+	for _, scope := range required {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, scope)
 		}
 	}
-
-	// Enviar la respuesta al cliente
-	w.WriteHeader(resp.StatusCode)
-	w.Write(body)
+	return missing
 }
 
-// GetCharacters maneja las peticiones de personajes
-func (h *GatewayHandler) GetCharacters(w http.ResponseWriter, r *http.Request) {
-	// Validar token
-	if !h.validateToken(w, r) {
-		return
+// clientIP devuelve la IP remota de la petición sin el puerto
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-	h.proxyToRickMorty(w, r)
+	return host
 }
 
-// GetCharacter maneja las peticiones de un personaje específico
-func (h *GatewayHandler) GetCharacter(w http.ResponseWriter, r *http.Request) {
-	// Validar token
-	if !h.validateToken(w, r) {
+// refundDebit pide al servicio de autenticación que reembolse el cargo de una petición
+func (h *GatewayHandler) refundDebit(debitID, reason string) {
+	if debitID == "" {
 		return
 	}
-	h.proxyToRickMorty(w, r)
-}
-
-// GetLocations maneja las peticiones de ubicaciones
-func (h *GatewayHandler) GetLocations(w http.ResponseWriter, r *http.Request) {
-	// Validar token
-	if !h.validateToken(w, r) {
+	body, _ := json.Marshal(map[string]string{"debit_id": debitID, "reason": reason})
+	refundURL := fmt.Sprintf("http://auth:%s/api/v1/internal/refund", h.authPort)
+	req, err := http.NewRequest("POST", refundURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[GATEWAY] Error creando el reembolso %s: %v", debitID, err)
 		return
 	}
-	h.proxyToRickMorty(w, r)
-}
-
-// GetLocation maneja las peticiones de una ubicación específica
-func (h *GatewayHandler) GetLocation(w http.ResponseWriter, r *http.Request) {
-	// Validar token
-	if !h.validateToken(w, r) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Auth", h.internalSecret)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.Printf("[GATEWAY] Error solicitando el reembolso %s: %v", debitID, err)
 		return
 	}
-	h.proxyToRickMorty(w, r)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[GATEWAY] El reembolso %s falló con estado %d", debitID, resp.StatusCode)
+	}
 }
 
-// GetEpisodes maneja las peticiones de episodios
-func (h *GatewayHandler) GetEpisodes(w http.ResponseWriter, r *http.Request) {
-	// Validar token
-	if !h.validateToken(w, r) {
-		return
-	}
-	h.proxyToRickMorty(w, r)
+// tokenInfo contiene los datos del token devueltos por el servicio de autenticación
+type tokenInfo struct {
+	Username          string   `json:"username"`
+	OrgID             int      `json:"org_id"`
+	OrgQuotaRemaining *int     `json:"org_quota_remaining"`
+	DebitID           string   `json:"debit_id"`
+	ExpiresAt         string   `json:"expires_at"`
+	Scopes            []string `json:"scopes"`
 }
 
-// GetEpisode maneja las peticiones de un episodio específico
-func (h *GatewayHandler) GetEpisode(w http.ResponseWriter, r *http.Request) {
-	// Validar token
-	if !h.validateToken(w, r) {
-		return
-	}
-	h.proxyToRickMorty(w, r)
+// rateLimitHeaders son las cabeceras de medición que el servicio de
+// autenticación devuelve en /validate
+var rateLimitHeaders = []string{
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+	"X-RateLimit-Window",
+	"Retry-After",
 }
 
 // validateToken valida el token con el servicio de autenticación
-func (h *GatewayHandler) validateToken(w http.ResponseWriter, r *http.Request) bool {
-	// Obtener el token de la cookie
+func (h *GatewayHandler) validateToken(w http.ResponseWriter, r *http.Request) (*tokenInfo, bool) {
+	// Obtener el token de la cookie o, para clientes de terminal, del header Authorization
 	cookie, err := r.Cookie("auth_token")
-	if err != nil {
+	bearer := r.Header.Get("Authorization")
+	if err != nil && !strings.HasPrefix(bearer, "Bearer ") {
 		sendJSONResponse(w, http.StatusUnauthorized, "error", "Token no encontrado", nil)
-		return false
+		return nil, false
+	}
+	token := strings.TrimPrefix(bearer, "Bearer ")
+	if cookie != nil {
+		token = cookie.Value
+	}
+
+	// Verificar firma y claims localmente: los tokens inválidos se rechazan sin
+	// llamar al servicio de autenticación
+	var claims *tokens.Claims
+	var cacheKey string
+	if len(h.tokenConfig.Secret) > 0 {
+		claims, err = h.tokenConfig.Parse(token)
+		if err != nil {
+			sendJSONResponse(w, http.StatusUnauthorized, "error", "Token inválido", nil)
+			return nil, false
+		}
+		// Con un plan de contabilización en lote, una validación reciente se
+		// reutiliza y el uso se reporta después. Los tokens de suplantación se
+		// validan siempre porque cada petición debe quedar auditada.
+		if h.asyncPlans[claims.Plan] && claims.ActorSubject() == "" {
+			cacheKey = validationCacheKey(token, r)
+			if info, ok := h.cache.get(cacheKey); ok {
+				cacheRequests.Inc("validations", "hit")
+				h.usage.add(token, strings.TrimSpace(r.Method+" "+r.URL.RequestURI()))
+				return &info, true
+			}
+			cacheRequests.Inc("validations", "miss")
+		}
 	}
 
 	// Crear la petición al servicio de autenticación
 	authURL := fmt.Sprintf("http://auth:%s/api/v1/validate", h.authPort)
-	req, err := http.NewRequest("GET", authURL, nil)
+	req, err := http.NewRequestWithContext(r.Context(), "GET", authURL, nil)
 	if err != nil {
 		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error al crear la petición de validación", nil)
-		return false
+		return nil, false
+	}
+	req.Header.Set(middleware.RequestIDHeader, middleware.RequestIDFromContext(r.Context()))
+
+	// Datos de la petición original para la auditoría y el ligado de tokens
+	req.Header.Set("X-Original-Method", r.Method)
+	req.Header.Set("X-Original-URI", r.URL.RequestURI())
+	req.Header.Set("X-Forwarded-For", clientIP(r))
+	req.Header.Set("User-Agent", r.UserAgent())
+	if thumbprint := r.Header.Get("X-Client-Thumbprint"); thumbprint != "" {
+		req.Header.Set("X-Client-Thumbprint", thumbprint)
+	}
+
+	// Agregar la cookie o el header al request
+	if cookie != nil {
+		req.AddCookie(cookie)
+	} else {
+		req.Header.Set("Authorization", bearer)
 	}
 
-	// Agregar la cookie al request
-	req.AddCookie(cookie)
+	// Si el servicio de autenticación está fallando, responder sin esperar su timeout
+	authBreaker := h.breakers.Get(authUpstream)
+	done, err := authBreaker.Allow()
+	if err != nil {
+		sendCircuitOpen(w, authUpstream, authBreaker)
+		return nil, false
+	}
 
 	// Realizar la petición
+	start := time.Now()
 	resp, err := h.client.Do(req)
+	middleware.RecordUpstream(r.Context(), authUpstream, time.Since(start))
 	if err != nil {
+		done(breaker.Failure)
 		sendJSONResponse(w, http.StatusInternalServerError, "error", "Error al comunicarse con el servicio de autenticación", nil)
-		return false
+		return nil, false
 	}
 	defer resp.Body.Close()
+	done(upstreamOutcome(resp.StatusCode))
+
+	// Reenviar al cliente el estado de sus ventanas de uso, también en los 429
+	for _, header := range rateLimitHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
 
 	// Si la validación falla, enviar el error al cliente
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
 		w.WriteHeader(resp.StatusCode)
 		w.Write(body)
-		return false
+		return nil, false
+	}
+
+	var validation struct {
+		Data tokenInfo `json:"data"`
+	}
+	json.Unmarshal(body, &validation)
+	if cacheKey != "" {
+		h.cache.put(cacheKey, token, validation.Data, claims.ExpiresAt.Time)
 	}
 
-	return true
+	// La expiración del token se desliza con cada uso: renovar la cookie para
+	// que el navegador no la descarte antes que el servicio de autenticación
+	if cookie != nil {
+		if expiresAt, err := time.Parse(time.RFC3339, validation.Data.ExpiresAt); err == nil {
+			http.SetCookie(w, gatewayCookie(&http.Cookie{
+				Name:     cookie.Name,
+				Value:    cookie.Value,
+				HttpOnly: true,
+				Expires:  expiresAt,
+			}, cookieSecure(r)))
+		}
+	}
+	return &validation.Data, true
 }
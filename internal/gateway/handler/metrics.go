@@ -0,0 +1,9 @@
+package handler
+
+import "github.com/yourusername/api_ricky_and_morty/internal/metrics"
+
+// cacheRequests cuenta las consultas a la caché de respuestas ("responses") y
+// a la de validaciones de token ("validations"). Las peticiones con no-cache o
+// no-store cuentan como bypass y no afectan a la tasa de aciertos.
+var cacheRequests = metrics.NewCounter("gateway_cache_requests_total",
+	"Consultas a las cachés del Gateway por caché y resultado (hit, miss o bypass)", "cache", "result")
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/breaker"
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/routes"
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
+)
+
+// proxyContextKey guarda en el contexto los datos de la petición que
+// necesitan los callbacks del proxy
+type proxyContextKey struct{}
+
+type proxyRequestInfo struct {
+	info  *tokenInfo
+	route routes.Route
+	// secure indica que el cliente llegó por HTTPS
+	secure bool
+	// done registra el resultado en el breaker del upstream
+	done func(breaker.Outcome)
+	// cacheKey es la clave con la que guardar la respuesta en la caché; vacía
+	// si no se guarda. in es la petición del cliente, cuyas cabeceras
+	// identifican la variante, e ifNoneMatch su If-None-Match.
+	cacheKey    string
+	in          *http.Request
+	ifNoneMatch string
+	// start es el momento en que se envió la petición al upstream
+	start time.Time
+}
+
+// newUpstreamProxy crea un proxy inverso con streaming para un upstream.
+// httputil.ReverseProxy elimina las cabeceras hop-by-hop en ambos sentidos y
+// cancela la petición al upstream si el cliente se desconecta.
+func (h *GatewayHandler) newUpstreamProxy(target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Transport: h.upstreamTransport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			data := pr.In.Context().Value(proxyContextKey{}).(*proxyRequestInfo)
+			pr.SetURL(target)
+			if data.route.UpstreamPath != "" {
+				pr.Out.URL.Path = singleJoiningSlash(target.Path, data.route.UpstreamPath)
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetXForwarded()
+			pr.Out.Header.Set("Forwarded", forwardedHeader(pr.In))
+
+			// Las credenciales del cliente no salen del Gateway salvo en las
+			// rutas que las necesitan, como /logout y /refresh
+			if !data.route.PassCredentials {
+				pr.Out.Header.Del("Authorization")
+				pr.Out.Header.Del("Cookie")
+			}
+
+			// La caché necesita la respuesta completa para calcular el ETag, así
+			// que el upstream no debe responder con un 304
+			if data.cacheKey != "" {
+				pr.Out.Header.Del("If-None-Match")
+				pr.Out.Header.Del("If-Modified-Since")
+			}
+
+			// Agregar el header de autenticación interna
+			pr.Out.Header.Set("X-Internal-Auth", "gateway-service")
+
+			// Contabilizar la petición a nombre de la organización del token
+			pr.Out.Header.Del("X-Org-ID")
+			if data.info.OrgID != 0 {
+				pr.Out.Header.Set("X-Org-ID", strconv.Itoa(data.info.OrgID))
+			}
+		},
+		// Enviar cada fragmento en cuanto llega, para respuestas en streaming
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			data := resp.Request.Context().Value(proxyContextKey{}).(*proxyRequestInfo)
+			middleware.RecordUpstream(resp.Request.Context(), data.route.Upstream, time.Since(data.start))
+			data.done(upstreamOutcome(resp.StatusCode))
+			// Las fallas del upstream no se cobran al usuario
+			if resp.StatusCode >= http.StatusInternalServerError {
+				go h.refundDebit(data.info.DebitID, fmt.Sprintf("%s respondió %d", data.route.Upstream, resp.StatusCode))
+			}
+			resp.Header.Set("Cache-Control", responseCacheControl(data.route, resp.StatusCode, resp.Header))
+			rewriteSetCookies(resp.Header, data.secure)
+			if data.cacheKey != "" {
+				return h.captureResponse(resp, data)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			data := r.Context().Value(proxyContextKey{}).(*proxyRequestInfo)
+			middleware.RecordUpstream(r.Context(), data.route.Upstream, time.Since(data.start))
+			go h.refundDebit(data.info.DebitID, "Error al comunicarse con el upstream "+data.route.Upstream)
+			switch {
+			case errors.Is(r.Context().Err(), context.Canceled):
+				// El cliente se desconectó: no hay a quién responder, y no es
+				// un fallo del upstream
+				data.done(breaker.Ignore)
+				log.Printf("[GATEWAY] Petición cancelada por el cliente: %s %s", r.Method, r.URL.Path)
+			case errors.Is(err, context.DeadlineExceeded):
+				data.done(breaker.Failure)
+				sendJSONResponse(w, http.StatusGatewayTimeout, "error", "El servicio "+data.route.Upstream+" no respondió a tiempo", nil)
+			default:
+				data.done(breaker.Failure)
+				log.Printf("[GATEWAY] Error en el upstream %s: %v", data.route.Upstream, err)
+				sendJSONResponse(w, http.StatusBadGateway, "error", "Error al comunicarse con el servicio "+data.route.Upstream, nil)
+			}
+		},
+	}
+}
+
+// forwardedHeader construye la cabecera Forwarded (RFC 7239) con el cliente,
+// el host y el protocolo de la petición original
+func forwardedHeader(r *http.Request) string {
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	forFor := clientIP(r)
+	if strings.Contains(forFor, ":") {
+		// Las IPv6 van entre corchetes y comillas
+		forFor = `"[` + forFor + `]"`
+	}
+	return fmt.Sprintf("for=%s;host=%q;proto=%s", forFor, r.Host, proto)
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch {
+	case strings.HasSuffix(a, "/") && strings.HasPrefix(b, "/"):
+		return a + b[1:]
+	case !strings.HasSuffix(a, "/") && !strings.HasPrefix(b, "/"):
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// proxyRequest reenvía la petición al upstream de la ruta respetando su timeout
+func (h *GatewayHandler) proxyRequest(w http.ResponseWriter, r *http.Request, info *tokenInfo, route routes.Route) {
+	proxy, ok := h.proxies[route.Upstream]
+	if !ok {
+		sendJSONResponse(w, http.StatusInternalServerError, "error", "Upstream no configurado", nil)
+		return
+	}
+
+	// Las respuestas vigentes en la caché no llegan al upstream
+	cacheKey, served := h.serveFromCache(w, r, route, info)
+	if served {
+		return
+	}
+
+	// Con el breaker abierto no se espera al upstream
+	upstreamBreaker := h.breakers.Get(route.Upstream)
+	done, err := upstreamBreaker.Allow()
+	if err != nil {
+		go h.refundDebit(info.DebitID, "Circuit breaker abierto para "+route.Upstream)
+		sendCircuitOpen(w, route.Upstream, upstreamBreaker)
+		return
+	}
+	// Si el proxy no llegó a registrar un resultado, liberar la petición
+	defer done(breaker.Ignore)
+
+	// Cada ruta define el timeout de su upstream
+	ctx, cancel := context.WithTimeout(r.Context(), route.Timeout.Duration)
+	defer cancel()
+	secure := cookieSecure(r)
+	ctx = context.WithValue(ctx, proxyContextKey{}, &proxyRequestInfo{
+		info:        info,
+		route:       route,
+		secure:      secure,
+		done:        done,
+		cacheKey:    cacheKey,
+		in:          r,
+		ifNoneMatch: r.Header.Get("If-None-Match"),
+		start:       time.Now(),
+	})
+
+	if info.OrgID != 0 {
+		orgID := strconv.Itoa(info.OrgID)
+		w.Header().Set("X-Org-ID", orgID)
+		if info.OrgQuotaRemaining != nil {
+			w.Header().Set("X-Org-Quota-Remaining", strconv.Itoa(*info.OrgQuotaRemaining))
+		}
+		log.Printf("[GATEWAY] org=%s user=%s %s %s", orgID, info.Username, r.Method, r.URL.Path)
+	}
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
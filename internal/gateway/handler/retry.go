@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/gateway/retry"
+)
+
+// retryPolicy lee la política de reintentos de las peticiones GET a los
+// upstreams: GATEWAY_RETRY_MAX_ATTEMPTS (3 intentos; 1 desactiva los
+// reintentos), GATEWAY_RETRY_BASE_MS (100), GATEWAY_RETRY_MAX_MS (2000),
+// GATEWAY_RETRY_STATUS (502,503,504), GATEWAY_RETRY_BUDGET_PERCENT (20) y
+// GATEWAY_RETRY_BUDGET_RESERVE (10 reintentos)
+func retryPolicy() retry.Policy {
+	status := os.Getenv("GATEWAY_RETRY_STATUS")
+	if status == "" {
+		status = "502,503,504"
+	}
+	retryable := map[int]bool{}
+	for _, code := range strings.Split(status, ",") {
+		if value, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+			retryable[value] = true
+		}
+	}
+	return retry.Policy{
+		MaxAttempts:     envInt("GATEWAY_RETRY_MAX_ATTEMPTS", 3),
+		BaseDelay:       time.Duration(envInt("GATEWAY_RETRY_BASE_MS", 100)) * time.Millisecond,
+		MaxDelay:        time.Duration(envInt("GATEWAY_RETRY_MAX_MS", 2000)) * time.Millisecond,
+		RetryableStatus: retryable,
+		BudgetRatio:     float64(envInt("GATEWAY_RETRY_BUDGET_PERCENT", 20)) / 100,
+		BudgetReserve:   float64(envInt("GATEWAY_RETRY_BUDGET_RESERVE", 10)),
+	}
+}
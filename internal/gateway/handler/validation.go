@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/usage"
+)
+
+// validationCache guarda brevemente los resultados positivos de /validate.
+// La clave incluye los datos del cliente para respetar el ligado de tokens.
+type validationCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedValidation
+}
+
+type cachedValidation struct {
+	token   string
+	info    tokenInfo
+	expires time.Time
+}
+
+func newValidationCache(ttl time.Duration) *validationCache {
+	return &validationCache{ttl: ttl, entries: map[string]cachedValidation{}}
+}
+
+// validationCacheKey combina el token con los datos del cliente que usa el ligado
+func validationCacheKey(token string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		token, clientIP(r), r.UserAgent(), r.Header.Get("X-Client-Thumbprint"),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *validationCache) get(key string) (tokenInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return tokenInfo{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return tokenInfo{}, false
+	}
+	return entry.info, true
+}
+
+// put guarda el resultado sin superar la expiración del token
+func (c *validationCache) put(key, token string, info tokenInfo, tokenExpires time.Time) {
+	expires := time.Now().Add(c.ttl)
+	if tokenExpires.Before(expires) {
+		expires = tokenExpires
+	}
+	// El cargo pertenece solo a la petición validada
+	info.DebitID = ""
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Limpieza oportunista para que la caché no crezca sin límite
+	if len(c.entries) > 10000 {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = cachedValidation{token: token, info: info, expires: expires}
+}
+
+// evictToken elimina todas las entradas de un token
+func (c *validationCache) evictToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if e.token == token {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// usageBatcher acumula las peticiones atendidas desde la caché y las reporta
+// periódicamente al servicio de autenticación
+type usageBatcher struct {
+	mu      sync.Mutex
+	pending map[string]*pendingUsage
+}
+
+type pendingUsage struct {
+	Token       string `json:"token"`
+	Count       int    `json:"count"`
+	Description string `json:"description"`
+}
+
+func newUsageBatcher() *usageBatcher {
+	return &usageBatcher{pending: map[string]*pendingUsage{}}
+}
+
+func (b *usageBatcher) add(token, description string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.pending[token]; ok {
+		p.Count++
+		return
+	}
+	b.pending[token] = &pendingUsage{Token: token, Count: 1, Description: description}
+}
+
+// take devuelve y vacía las peticiones pendientes, hasta los máximos del lote
+func (b *usageBatcher) take() []*pendingUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := make([]*pendingUsage, 0, min(len(b.pending), usage.MaxBatchEntries))
+	remaining := map[string]*pendingUsage{}
+	for token, p := range b.pending {
+		if len(entries) == usage.MaxBatchEntries {
+			remaining[token] = p
+			continue
+		}
+		if p.Count > usage.MaxBatchCount {
+			rest := *p
+			rest.Count -= usage.MaxBatchCount
+			remaining[token] = &rest
+			p.Count = usage.MaxBatchCount
+		}
+		entries = append(entries, p)
+	}
+	b.pending = remaining
+	return entries
+}
+
+// restore devuelve al lote las peticiones que no se pudieron reportar
+func (b *usageBatcher) restore(entries []*pendingUsage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range entries {
+		if p, ok := b.pending[e.Token]; ok {
+			p.Count += e.Count
+			continue
+		}
+		b.pending[e.Token] = e
+	}
+}
+
+// startUsageFlusher reporta el uso acumulado cada interval
+func (h *GatewayHandler) startUsageFlusher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			h.flushUsage()
+		}
+	}()
+}
+
+// flushUsage envía el lote a /internal/usage. Los tokens con peticiones
+// rechazadas salen de la caché para que la siguiente petición se valide de
+// forma síncrona y reciba el error correspondiente.
+func (h *GatewayHandler) flushUsage() {
+	entries := h.usage.take()
+	if len(entries) == 0 {
+		return
+	}
+	body, _ := json.Marshal(map[string]interface{}{"entries": entries})
+	usageURL := fmt.Sprintf("http://auth:%s/api/v1/internal/usage", h.authPort)
+	req, err := http.NewRequest("POST", usageURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[GATEWAY] Error creando el reporte de uso: %v", err)
+		h.usage.restore(entries)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Auth", h.internalSecret)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.Printf("[GATEWAY] Error reportando el uso, se reintentará: %v", err)
+		h.usage.restore(entries)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[GATEWAY] El reporte de uso falló con estado %d, se reintentará", resp.StatusCode)
+		h.usage.restore(entries)
+		return
+	}
+	var result struct {
+		Data struct {
+			Results []struct {
+				Accepted int    `json:"accepted"`
+				Rejected string `json:"rejected"`
+			} `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("[GATEWAY] Respuesta inválida al reportar el uso: %v", err)
+		return
+	}
+	for i, r := range result.Data.Results {
+		if i < len(entries) && r.Rejected != "" {
+			h.cache.evictToken(entries[i].Token)
+			log.Printf("[GATEWAY] Uso rechazado (%s) tras %d de %d peticiones", r.Rejected, r.Accepted, entries[i].Count)
+		}
+	}
+}
+
+// asyncUsagePlans son los planes cuyo uso se puede contabilizar en lote
+// (GATEWAY_ASYNC_USAGE_PLANS, por defecto pro y enterprise). El resto se
+// valida siempre de forma síncrona para que sus límites sean exactos.
+func asyncUsagePlans() map[string]bool {
+	value, ok := os.LookupEnv("GATEWAY_ASYNC_USAGE_PLANS")
+	if !ok {
+		value = "pro,enterprise"
+	}
+	plans := map[string]bool{}
+	for _, plan := range strings.Split(value, ",") {
+		if plan = strings.TrimSpace(plan); plan != "" {
+			plans[plan] = true
+		}
+	}
+	return plans
+}
+
+// envSeconds lee una duración en segundos con un valor por defecto
+func envSeconds(name string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || seconds < 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/usage"
+)
+
+func TestUsageBatcherTakeCaps(t *testing.T) {
+	b := newUsageBatcher()
+	for i := 0; i < usage.MaxBatchCount+5; i++ {
+		b.add("big", "GET /api/v1/characters")
+	}
+	for i := 0; i < usage.MaxBatchEntries; i++ {
+		b.add(fmt.Sprintf("token-%d", i), "GET /api/v1/characters")
+	}
+
+	total := 0
+	for round := 0; round < 3; round++ {
+		entries := b.take()
+		if len(entries) > usage.MaxBatchEntries {
+			t.Fatalf("lote %d con %d entradas", round, len(entries))
+		}
+		for _, e := range entries {
+			if e.Count > usage.MaxBatchCount {
+				t.Fatalf("lote %d: %s con %d peticiones", round, e.Token, e.Count)
+			}
+			total += e.Count
+		}
+	}
+	// Lo que excede los máximos se reporta en los lotes siguientes, sin perderse
+	if want := usage.MaxBatchCount + 5 + usage.MaxBatchEntries; total != want {
+		t.Fatalf("total = %d, se esperaba %d", total, want)
+	}
+}
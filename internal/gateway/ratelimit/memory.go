@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore guarda los TAT en memoria. Cada réplica del Gateway lleva su
+// propia cuenta; para compartirla use RESPStore.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]time.Time{}}
+}
+
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Limpieza oportunista: una clave cuyo TAT ya pasó tiene la ráfaga completa
+	if len(s.entries) > 10000 {
+		for k, tat := range s.entries {
+			if tat.Before(now) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	result, tat := gcra(s.entries[key], limit, now)
+	if result.Allowed {
+		s.entries[key] = tat
+	}
+	return result, nil
+}
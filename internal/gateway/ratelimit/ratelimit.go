@@ -0,0 +1,76 @@
+// Package ratelimit implementa un limitador GCRA (Generic Cell Rate
+// Algorithm), equivalente a un token bucket que solo guarda un instante por
+// clave: el TAT (theoretical arrival time) de la siguiente petición.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit permite Rate peticiones por Period con ráfagas de hasta Burst
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// interval es el tiempo que "cuesta" cada petición
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Rate)
+}
+
+// burst devuelve el tamaño de la ráfaga, que por defecto es la tasa
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// Result es la decisión del limitador para una petición
+type Result struct {
+	Allowed bool
+	// Limit es el tamaño de la ráfaga
+	Limit     int
+	Remaining int
+	// ResetAfter es el tiempo hasta que la ráfaga vuelve a estar completa
+	ResetAfter time.Duration
+	// RetryAfter es el tiempo hasta que se admita la siguiente petición; solo
+	// tiene sentido si la petición fue rechazada
+	RetryAfter time.Duration
+}
+
+// Store guarda el TAT de cada clave y decide de forma atómica si se admite
+// una petición
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// gcra calcula la decisión a partir del TAT guardado (cero si no hay) y
+// devuelve el nuevo TAT, que solo se debe guardar si la petición se admite
+func gcra(tat time.Time, limit Limit, now time.Time) (Result, time.Time) {
+	interval := limit.interval()
+	burst := limit.burst()
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	// Instante a partir del cual se admite la petición
+	allowAt := newTAT.Add(-interval * time.Duration(burst))
+	if now.Before(allowAt) {
+		return Result{
+			Allowed:    false,
+			Limit:      burst,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}, tat
+	}
+	return Result{
+		Allowed:    true,
+		Limit:      burst,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTAT.Sub(now),
+	}, newTAT
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGCRA(t *testing.T) {
+	limit := Limit{Rate: 2, Period: time.Second}
+	base := time.Unix(1700000000, 0)
+	ms := time.Millisecond
+
+	// Cada paso usa el TAT que dejó el anterior si se admitió
+	tests := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}{
+		{"primera petición", 0, true, 1, 0, 500 * ms},
+		{"agota la ráfaga", 0, true, 0, 0, time.Second},
+		{"ráfaga agotada", 100 * ms, false, 0, 400 * ms, 900 * ms},
+		{"recupera una petición", 500 * ms, true, 0, 0, time.Second},
+		{"tras un período completo", 3 * time.Second, true, 1, 0, 500 * ms},
+	}
+	var tat time.Time
+	for _, tt := range tests {
+		result, next := gcra(tat, limit, base.Add(tt.at))
+		if result.Allowed != tt.allowed || result.Remaining != tt.remaining ||
+			result.RetryAfter != tt.retryAfter || result.ResetAfter != tt.resetAfter || result.Limit != 2 {
+			t.Fatalf("%s: %+v", tt.name, result)
+		}
+		if result.Allowed {
+			tat = next
+		}
+	}
+}
+
+func TestGCRABurst(t *testing.T) {
+	limit := Limit{Rate: 1, Period: time.Minute, Burst: 5}
+	store := NewMemoryStore()
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		result, _ := store.Allow(context.Background(), "ip:203.0.113.7", limit, now)
+		if !result.Allowed || result.Remaining != 4-i {
+			t.Fatalf("petición %d: %+v", i, result)
+		}
+	}
+	result, _ := store.Allow(context.Background(), "ip:203.0.113.7", limit, now)
+	if result.Allowed || result.RetryAfter != time.Minute {
+		t.Fatalf("petición fuera de la ráfaga: %+v", result)
+	}
+	// Las claves son independientes
+	if result, _ := store.Allow(context.Background(), "ip:198.51.100.1", limit, now); !result.Allowed {
+		t.Fatalf("otra clave: %+v", result)
+	}
+}
@@ -0,0 +1,273 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrContention indica que la clave cambió en todos los intentos de actualizarla
+var ErrContention = errors.New("ratelimit: demasiada contención en la clave")
+
+const (
+	respPoolSize    = 8
+	respMaxAttempts = 5
+	respTimeout     = 500 * time.Millisecond
+	respKeyPrefix   = "ratelimit:"
+)
+
+// RESPStore guarda los TAT en un servidor que hable el protocolo de Redis
+// (RESP), de modo que todas las réplicas del Gateway compartan los límites.
+// Cada actualización usa WATCH/MULTI/EXEC, por lo que solo necesita los
+// comandos GET, SET, WATCH, UNWATCH, MULTI y EXEC.
+type RESPStore struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *respConn
+}
+
+// NewRESPStore crea el store a partir de una URL redis://[:password@]host:port[/db]
+func NewRESPStore(rawURL string) (*RESPStore, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "redis" || parsed.Host == "" {
+		return nil, fmt.Errorf("URL de Redis inválida %q", rawURL)
+	}
+	store := &RESPStore{addr: parsed.Host, pool: make(chan *respConn, respPoolSize)}
+	if _, _, err := net.SplitHostPort(store.addr); err != nil {
+		store.addr = net.JoinHostPort(store.addr, "6379")
+	}
+	if parsed.User != nil {
+		store.password, _ = parsed.User.Password()
+	}
+	if db := strings.Trim(parsed.Path, "/"); db != "" {
+		if store.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("base de datos de Redis inválida %q", db)
+		}
+	}
+	return store, nil
+}
+
+func (s *RESPStore) Allow(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	for attempt := 0; attempt < respMaxAttempts; attempt++ {
+		conn, err := s.conn(ctx)
+		if err != nil {
+			return Result{}, err
+		}
+		result, done, err := conn.allow(ctx, respKeyPrefix+key, limit, now)
+		if err != nil {
+			conn.close()
+			// Una conexión reutilizada puede haberse cerrado, por ejemplo si el
+			// servidor se reinició: se descartan las del pool y se reintenta
+			if conn.reused && !errors.As(err, new(respError)) {
+				s.drain()
+				continue
+			}
+			return Result{}, err
+		}
+		s.release(conn)
+		if done {
+			return result, nil
+		}
+	}
+	return Result{}, ErrContention
+}
+
+// conn devuelve una conexión del pool o abre una nueva
+func (s *RESPStore) conn(ctx context.Context) (*respConn, error) {
+	select {
+	case conn := <-s.pool:
+		conn.reused = true
+		return conn, nil
+	default:
+	}
+	dialer := net.Dialer{Timeout: respTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &respConn{conn: netConn, reader: bufio.NewReader(netConn)}
+	conn.conn.SetDeadline(time.Now().Add(respTimeout))
+	if s.password != "" {
+		if _, err := conn.do("AUTH", s.password); err != nil {
+			conn.close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.do("SELECT", strconv.Itoa(s.db)); err != nil {
+			conn.close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// release devuelve la conexión al pool, o la cierra si está lleno
+func (s *RESPStore) release(conn *respConn) {
+	select {
+	case s.pool <- conn:
+	default:
+		conn.close()
+	}
+}
+
+// drain cierra las conexiones inactivas del pool
+func (s *RESPStore) drain() {
+	for {
+		select {
+		case conn := <-s.pool:
+			conn.close()
+		default:
+			return
+		}
+	}
+}
+
+type respConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// reused indica que la conexión salió del pool
+	reused bool
+}
+
+// respError es una respuesta de error del servidor
+type respError string
+
+func (e respError) Error() string {
+	return "redis: " + string(e)
+}
+
+// allow ejecuta un intento de GCRA. done es false si otra petición modificó
+// la clave entre el GET y el EXEC y hay que reintentar.
+func (c *respConn) allow(ctx context.Context, key string, limit Limit, now time.Time) (result Result, done bool, err error) {
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > respTimeout {
+		deadline = time.Now().Add(respTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	if _, err := c.do("WATCH", key); err != nil {
+		return Result{}, false, err
+	}
+	reply, err := c.do("GET", key)
+	if err != nil {
+		return Result{}, false, err
+	}
+	var tat time.Time
+	if value, ok := reply.(string); ok {
+		nanos, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Result{}, false, fmt.Errorf("valor inválido en %s: %q", key, value)
+		}
+		tat = time.Unix(0, nanos)
+	}
+
+	result, newTAT := gcra(tat, limit, now)
+	if !result.Allowed {
+		_, err := c.do("UNWATCH")
+		return result, true, err
+	}
+
+	// La clave expira cuando la ráfaga vuelve a estar completa
+	ttl := newTAT.Sub(now).Milliseconds() + 1
+	if err := c.send("MULTI"); err != nil {
+		return Result{}, false, err
+	}
+	if err := c.send("SET", key, strconv.FormatInt(newTAT.UnixNano(), 10), "PX", strconv.FormatInt(ttl, 10)); err != nil {
+		return Result{}, false, err
+	}
+	if err := c.send("EXEC"); err != nil {
+		return Result{}, false, err
+	}
+	// +OK de MULTI, +QUEUED de SET y la respuesta de EXEC
+	for i := 0; i < 2; i++ {
+		if _, err := c.read(); err != nil {
+			return Result{}, false, err
+		}
+	}
+	reply, err = c.read()
+	if err != nil {
+		return Result{}, false, err
+	}
+	// EXEC devuelve nulo si la clave cambió desde el WATCH
+	return result, reply != nil, nil
+}
+
+func (c *respConn) do(args ...string) (interface{}, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// send escribe un comando como arreglo de bulk strings
+func (c *respConn) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+// read lee una respuesta: string, int64, []interface{}, nil o respError
+func (c *respConn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: respuesta vacía")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, respError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: respuesta desconocida %q", line)
+}
+
+func (c *respConn) close() {
+	c.conn.Close()
+}
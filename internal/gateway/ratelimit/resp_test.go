@@ -0,0 +1,314 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis implementa los comandos que usa RESPStore con la semántica de
+// WATCH de Redis: EXEC devuelve nulo si una clave vigilada cambió
+type fakeRedis struct {
+	addr     string
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	versions map[string]int
+	conns    []net.Conn
+	// beforeExec se llama antes de cada EXEC, por ejemplo para simular que
+	// otra réplica escribe la clave
+	beforeExec func(r *fakeRedis)
+}
+
+// start empieza a aceptar conexiones; password y beforeExec se fijan antes
+func (r *fakeRedis) start(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	r.addr = listener.Addr().String()
+	r.values, r.versions = map[string]string{}, map[string]int{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			r.mu.Lock()
+			r.conns = append(r.conns, conn)
+			r.mu.Unlock()
+			go r.serve(conn)
+		}
+	}()
+	t.Cleanup(r.closeConns)
+	return r
+}
+
+// set escribe una clave como lo haría otro cliente
+func (r *fakeRedis) set(key, value string) {
+	r.values[key] = value
+	r.versions[key]++
+}
+
+// closeConns cierra las conexiones abiertas, como un reinicio del servidor
+func (r *fakeRedis) closeConns() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+	r.conns = nil
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	// El cliente envía los comandos como arreglos de bulk strings, así que
+	// se pueden leer con el mismo parser
+	client := &respConn{conn: conn, reader: bufio.NewReader(conn)}
+	watched := map[string]int{}
+	var queued [][]string
+	multi := false
+	authed := r.password == ""
+	for {
+		reply, err := client.read()
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		if !authed && args[0] != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		if args[0] == "EXEC" && r.beforeExec != nil {
+			r.mu.Lock()
+			r.beforeExec(r)
+			r.mu.Unlock()
+		}
+
+		r.mu.Lock()
+		var out string
+		switch args[0] {
+		case "AUTH":
+			if args[1] != r.password {
+				out = "-WRONGPASS invalid password\r\n"
+				break
+			}
+			authed = true
+			out = "+OK\r\n"
+		case "SELECT", "UNWATCH":
+			watched = map[string]int{}
+			out = "+OK\r\n"
+		case "WATCH":
+			watched[args[1]] = r.versions[args[1]]
+			out = "+OK\r\n"
+		case "GET":
+			if value, ok := r.values[args[1]]; ok {
+				out = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				out = "$-1\r\n"
+			}
+		case "MULTI":
+			multi = true
+			out = "+OK\r\n"
+		case "SET":
+			if multi {
+				queued = append(queued, args)
+				out = "+QUEUED\r\n"
+				break
+			}
+			r.set(args[1], args[2])
+			out = "+OK\r\n"
+		case "EXEC":
+			out = fmt.Sprintf("*%d\r\n", len(queued))
+			for key, version := range watched {
+				if r.versions[key] != version {
+					out = "*-1\r\n"
+				}
+			}
+			if out != "*-1\r\n" {
+				for _, cmd := range queued {
+					r.set(cmd[1], cmd[2])
+					out += "+OK\r\n"
+				}
+			}
+			watched, queued, multi = map[string]int{}, nil, false
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		r.mu.Unlock()
+		io.WriteString(conn, out)
+	}
+}
+
+func (r *fakeRedis) tat(t *testing.T, key string) time.Time {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nanos, err := strconv.ParseInt(r.values[respKeyPrefix+key], 10, 64)
+	if err != nil {
+		t.Fatalf("TAT de %s: %v", key, err)
+	}
+	return time.Unix(0, nanos)
+}
+
+func TestRESPStoreAllow(t *testing.T) {
+	server := (&fakeRedis{password: "secret"}).start(t)
+	store, err := NewRESPStore("redis://:secret@" + server.addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := Limit{Rate: 2, Period: time.Second}
+	now := time.Unix(1700000000, 0)
+	for i, want := range []bool{true, true, false} {
+		result, err := store.Allow(context.Background(), "ip:203.0.113.7", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != want {
+			t.Fatalf("petición %d: %+v", i, result)
+		}
+	}
+	if got := server.tat(t, "ip:203.0.113.7"); !got.Equal(now.Add(time.Second)) {
+		t.Fatalf("TAT = %v", got)
+	}
+}
+
+func TestRESPStoreContention(t *testing.T) {
+	tests := []struct {
+		name      string
+		conflicts int
+		wantErr   error
+	}{
+		{"sin conflictos", 0, nil},
+		{"reintenta tras dos conflictos", 2, nil},
+		{"se rinde tras todos los intentos", respMaxAttempts, ErrContention},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := tt.conflicts
+			// Otra réplica escribe la clave entre el WATCH y el EXEC
+			server := (&fakeRedis{beforeExec: func(r *fakeRedis) {
+				if conflicts > 0 {
+					conflicts--
+					r.set(respKeyPrefix+"user:rick", "0")
+				}
+			}}).start(t)
+			store, err := NewRESPStore("redis://" + server.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Unix(1700000000, 0)
+			result, err := store.Allow(context.Background(), "user:rick", Limit{Rate: 10, Period: time.Second}, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			if err == nil && (!result.Allowed || !server.tat(t, "user:rick").Equal(now.Add(100*time.Millisecond))) {
+				t.Fatalf("resultado = %+v", result)
+			}
+		})
+	}
+}
+
+func TestRESPStoreConcurrent(t *testing.T) {
+	server := (&fakeRedis{}).start(t)
+	store, err := NewRESPStore("redis://" + server.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := Limit{Rate: 10, Period: time.Second}
+	now := time.Unix(1700000000, 0)
+
+	var mu sync.Mutex
+	allowed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Allow(context.Background(), "user:morty", limit, now)
+			if err != nil && !errors.Is(err, ErrContention) {
+				t.Error(err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed == 0 || allowed > 10 {
+		t.Fatalf("admitidas = %d", allowed)
+	}
+	// Cada petición admitida avanzó el TAT: no se perdió ninguna escritura
+	if got := server.tat(t, "user:morty"); !got.Equal(now.Add(time.Duration(allowed) * limit.interval())) {
+		t.Fatalf("TAT = %v con %d admitidas", got, allowed)
+	}
+}
+
+func TestRESPStoreReconnect(t *testing.T) {
+	server := (&fakeRedis{}).start(t)
+	store, err := NewRESPStore("redis://" + server.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := Limit{Rate: 10, Period: time.Second}
+	now := time.Unix(1700000000, 0)
+	if _, err := store.Allow(context.Background(), "user:rick", limit, now); err != nil {
+		t.Fatal(err)
+	}
+	// El servidor cierra la conexión que quedó en el pool
+	server.closeConns()
+	result, err := store.Allow(context.Background(), "user:rick", limit, now)
+	if err != nil {
+		t.Fatalf("no se reconectó: %v", err)
+	}
+	if !result.Allowed || result.Remaining != 8 {
+		t.Fatalf("resultado = %+v", result)
+	}
+}
+
+func TestNewRESPStore(t *testing.T) {
+	tests := []struct {
+		url      string
+		addr     string
+		password string
+		db       int
+		wantErr  bool
+	}{
+		{url: "redis://redis", addr: "redis:6379"},
+		{url: "redis://:pw@redis:6380/3", addr: "redis:6380", password: "pw", db: 3},
+		{url: "http://redis:6379", wantErr: true},
+		{url: "redis://redis/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		store, err := NewRESPStore(tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: se esperaba un error", tt.url)
+			}
+			continue
+		}
+		if err != nil || store.addr != tt.addr || store.password != tt.password || store.db != tt.db {
+			t.Errorf("%s: %+v, %v", tt.url, store, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package retry implementa un http.RoundTripper que reintenta las peticiones
+// idempotentes con backoff exponencial con jitter, respeta el Retry-After del
+// upstream y limita los reintentos con un presupuesto para no amplificar una
+// caída con una tormenta de reintentos.
+package retry
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Policy configura los reintentos
+type Policy struct {
+	// MaxAttempts es el número total de intentos, incluido el primero
+	MaxAttempts int
+	// BaseDelay es la espera antes del primer reintento; se duplica en cada uno
+	BaseDelay time.Duration
+	// MaxDelay limita la espera de cada reintento, también la pedida en Retry-After
+	MaxDelay time.Duration
+	// RetryableStatus son los códigos de respuesta que se reintentan
+	RetryableStatus map[int]bool
+	// BudgetRatio es la fracción de peticiones que se pueden reintentar
+	BudgetRatio float64
+	// BudgetReserve es el número de reintentos disponibles aunque haya pocas peticiones
+	BudgetReserve float64
+}
+
+// Transport reintenta las peticiones GET y HEAD sobre otro RoundTripper
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+	budget *budget
+}
+
+func NewTransport(base http.RoundTripper, policy Policy) *Transport {
+	return &Transport{Base: base, Policy: policy, budget: newBudget(policy.BudgetRatio, policy.BudgetReserve)}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.budget.deposit()
+	if !t.retryable(req) {
+		return t.Base.RoundTrip(req)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := t.Base.RoundTrip(req)
+		if attempt >= t.Policy.MaxAttempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		delay := t.backoff(attempt)
+		if err == nil {
+			if !t.Policy.RetryableStatus[resp.StatusCode] {
+				return resp, nil
+			}
+			// Si el upstream pide esperar más de lo que estamos dispuestos, su
+			// respuesta llega tal cual al cliente
+			if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				if wait > t.Policy.MaxDelay {
+					return resp, nil
+				}
+				delay = wait
+			}
+		}
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+		if !t.budget.withdraw() {
+			return resp, err
+		}
+		if resp != nil {
+			// Vaciar el cuerpo para reutilizar la conexión
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if !sleep(req.Context(), delay) {
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryable indica si la petición se puede repetir sin efectos adicionales
+func (t *Transport) retryable(req *http.Request) bool {
+	if t.Policy.MaxAttempts <= 1 {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// backoff devuelve una espera aleatoria entre 0 y BaseDelay·2^(attempt-1),
+// sin superar MaxDelay ("full jitter")
+func (t *Transport) backoff(attempt int) time.Duration {
+	ceiling := t.Policy.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > t.Policy.MaxDelay {
+		ceiling = t.Policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// retryAfter interpreta Retry-After en segundos o como fecha HTTP
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// budget limita los reintentos a una fracción de las peticiones. Cada
+// petición deposita ratio y cada reintento retira 1, con un saldo máximo de
+// reserve, que también es el saldo inicial.
+type budget struct {
+	mu      sync.Mutex
+	ratio   float64
+	reserve float64
+	balance float64
+}
+
+func newBudget(ratio, reserve float64) *budget {
+	return &budget{ratio: ratio, reserve: reserve, balance: reserve}
+}
+
+func (b *budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance += b.ratio
+	if b.balance > b.reserve {
+		b.balance = b.reserve
+	}
+}
+
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
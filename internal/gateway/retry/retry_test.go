@@ -0,0 +1,168 @@
+package retry
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeUpstream responde cada intento con la respuesta o el error siguiente:
+// un código de estado, una *http.Response o un error
+type fakeUpstream struct {
+	replies []interface{}
+	calls   int
+}
+
+func (f *fakeUpstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	reply := f.replies[f.calls]
+	f.calls++
+	switch reply := reply.(type) {
+	case error:
+		return nil, reply
+	case int:
+		return &http.Response{StatusCode: reply, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	case *http.Response:
+		reply.Request = req
+		return reply, nil
+	}
+	panic("respuesta desconocida")
+}
+
+var testPolicy = Policy{
+	MaxAttempts:     3,
+	BaseDelay:       time.Millisecond,
+	MaxDelay:        5 * time.Millisecond,
+	RetryableStatus: map[int]bool{502: true, 503: true, 504: true},
+	BudgetRatio:     1,
+	BudgetReserve:   10,
+}
+
+func TestTransportRetries(t *testing.T) {
+	errReset := errors.New("connection reset by peer")
+	slow := &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": {"120"}}, Body: http.NoBody}
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		replies    []interface{}
+		wantStatus int
+		wantErr    bool
+		wantCalls  int
+	}{
+		{name: "sin fallos", method: "GET", replies: []interface{}{200}, wantStatus: 200, wantCalls: 1},
+		{name: "reintenta un 503", method: "GET", replies: []interface{}{503, 200}, wantStatus: 200, wantCalls: 2},
+		{name: "reintenta un error de red", method: "GET", replies: []interface{}{errReset, 200}, wantStatus: 200, wantCalls: 2},
+		{name: "agota los intentos", method: "GET", replies: []interface{}{502, 503, 504}, wantStatus: 504, wantCalls: 3},
+		{name: "un 500 no se reintenta", method: "GET", replies: []interface{}{500}, wantStatus: 500, wantCalls: 1},
+		{name: "POST no se reintenta", method: "POST", replies: []interface{}{503}, wantStatus: 503, wantCalls: 1},
+		{name: "GET con cuerpo no se reintenta", method: "GET", body: "x", replies: []interface{}{503}, wantStatus: 503, wantCalls: 1},
+		{name: "Retry-After mayor que MaxDelay", method: "GET", replies: []interface{}{slow}, wantStatus: 503, wantCalls: 1},
+		{name: "error en el último intento", method: "HEAD", replies: []interface{}{503, 503, errReset}, wantErr: true, wantCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeUpstream{replies: tt.replies}
+			transport := NewTransport(upstream, testPolicy)
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			resp, err := transport.RoundTrip(httptest.NewRequest(tt.method, "http://rickmorty/api/v1/characters", body))
+			if upstream.calls != tt.wantCalls {
+				t.Fatalf("intentos = %d, se esperaban %d", upstream.calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("se esperaba un error")
+				}
+				return
+			}
+			if err != nil || resp.StatusCode != tt.wantStatus {
+				t.Fatalf("respuesta = %v, %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestTransportBudget(t *testing.T) {
+	// Sin depósitos solo quedan los 2 reintentos de la reserva
+	policy := testPolicy
+	policy.BudgetRatio = 0
+	policy.BudgetReserve = 2
+	upstream := &fakeUpstream{replies: []interface{}{503, 503, 503, 503, 503}}
+	transport := NewTransport(upstream, policy)
+
+	resp, _ := transport.RoundTrip(httptest.NewRequest("GET", "http://rickmorty/", nil))
+	if upstream.calls != 3 || resp.StatusCode != 503 {
+		t.Fatalf("primera petición: %d intentos", upstream.calls)
+	}
+	transport.RoundTrip(httptest.NewRequest("GET", "http://rickmorty/", nil))
+	if upstream.calls != 4 {
+		t.Fatalf("con el presupuesto agotado se reintentó: %d intentos", upstream.calls)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := newBudget(0.25, 2)
+	for i := 0; i < 2; i++ {
+		if !b.withdraw() {
+			t.Fatalf("reintento %d de la reserva rechazado", i)
+		}
+	}
+	if b.withdraw() {
+		t.Fatal("se reintentó sin saldo")
+	}
+	// Cuatro peticiones al 25% pagan un reintento
+	for i := 0; i < 3; i++ {
+		b.deposit()
+	}
+	if b.withdraw() {
+		t.Fatal("se reintentó con 0.75 de saldo")
+	}
+	b.deposit()
+	if !b.withdraw() {
+		t.Fatal("cuatro peticiones no pagaron un reintento")
+	}
+	// El saldo no supera la reserva
+	for i := 0; i < 100; i++ {
+		b.deposit()
+	}
+	if !b.withdraw() || !b.withdraw() || b.withdraw() {
+		t.Fatal("el saldo superó la reserva")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"pronto", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %v, %v", tt.value, got, ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transport := NewTransport(nil, Policy{BaseDelay: 10 * time.Millisecond, MaxDelay: 25 * time.Millisecond})
+	for attempt, ceiling := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 25 * time.Millisecond, 60: 25 * time.Millisecond} {
+		for i := 0; i < 50; i++ {
+			if d := transport.backoff(attempt); d < 0 || d > ceiling {
+				t.Fatalf("intento %d: espera %v fuera de [0, %v]", attempt, d, ceiling)
+			}
+		}
+	}
+}
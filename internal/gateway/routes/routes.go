@@ -0,0 +1,205 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Duration acepta duraciones en formato de Go ("10s", "1m") en el JSON
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duración inválida %s: use un texto como \"10s\"", data)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// CachePolicy indica si y durante cuánto tiempo se pueden cachear las
+// respuestas de la ruta. Private restringe la caché a cada usuario.
+type CachePolicy struct {
+	TTL     Duration `json:"ttl"`
+	Private bool     `json:"private"`
+}
+
+// CacheControl devuelve la cabecera Cache-Control correspondiente a la política
+func (c *CachePolicy) CacheControl() string {
+	if c == nil || c.TTL.Duration <= 0 {
+		return "no-store"
+	}
+	visibility := "public"
+	if c.Private {
+		visibility = "private"
+	}
+	return fmt.Sprintf("%s, max-age=%d", visibility, int(c.TTL.Seconds()))
+}
+
+// Claves por las que se puede limitar la tasa de peticiones
+const (
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+	RateLimitByIP     = "ip"
+)
+
+// RateLimitPolicy admite Rate peticiones por Period, con ráfagas de hasta
+// Burst (por defecto Rate), por cada valor de Key
+type RateLimitPolicy struct {
+	Rate   int      `json:"rate"`
+	Period Duration `json:"period"`
+	Burst  int      `json:"burst"`
+	// Key es user, api_key o ip. Sin token, user y api_key limitan por IP.
+	Key string `json:"key"`
+}
+
+func (p *RateLimitPolicy) validate() error {
+	if p.Rate <= 0 {
+		return fmt.Errorf("rate_limit: rate debe ser mayor que 0")
+	}
+	if p.Period.Duration <= 0 {
+		p.Period.Duration = time.Second
+	}
+	if p.Burst < 0 {
+		return fmt.Errorf("rate_limit: burst no puede ser negativo")
+	}
+	switch p.Key {
+	case "":
+		p.Key = RateLimitByUser
+	case RateLimitByUser, RateLimitByAPIKey, RateLimitByIP:
+	default:
+		return fmt.Errorf("rate_limit: key desconocida %q", p.Key)
+	}
+	return nil
+}
+
+// Route describe un endpoint público del Gateway
+type Route struct {
+	// Path es el patrón de gorilla/mux, por ejemplo /api/v1/character/{id}
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	// Upstream es el nombre de un servicio de la tabla de upstreams
+	Upstream string `json:"upstream"`
+	// UpstreamPath reemplaza la ruta al reenviar; vacío conserva la original
+	UpstreamPath string `json:"upstream_path"`
+	// Auth exige un token válido; por defecto es true
+	Auth *bool `json:"auth"`
+	// Scopes son los scopes que debe tener el token
+	Scopes []string `json:"scopes"`
+	// PassCredentials reenvía la cookie y el header Authorization al upstream,
+	// necesario para las rutas del propio servicio de autenticación
+	PassCredentials bool         `json:"pass_credentials"`
+	Timeout         Duration     `json:"timeout"`
+	Cache           *CachePolicy `json:"cache"`
+	// RateLimit limita la ruta con su propio contador; sin él se aplica el
+	// límite por defecto de la tabla, compartido entre las rutas
+	RateLimit *RateLimitPolicy `json:"rate_limit"`
+}
+
+// RequiresAuth indica si la ruta exige un token
+func (r Route) RequiresAuth() bool {
+	return r.Auth == nil || *r.Auth
+}
+
+// Table es la configuración completa de rutas del Gateway
+type Table struct {
+	// Upstreams asocia cada nombre a su URL base. Se expanden las variables
+	// con la función que recibe Load, por ejemplo http://rickmorty:${RICKMORTY_SERVICE_PORT}
+	Upstreams map[string]string `json:"upstreams"`
+	// DefaultRateLimit se aplica a las rutas sin rate_limit propio
+	DefaultRateLimit *RateLimitPolicy `json:"default_rate_limit"`
+	Routes           []Route          `json:"routes"`
+}
+
+const defaultTimeout = 10 * time.Second
+
+// internalPathPrefix es el prefijo de los endpoints internos del servicio de
+// autenticación (reembolsos y uso en lote)
+const internalPathPrefix = "/api/v1/internal"
+
+// Load lee y valida la tabla de rutas de un archivo JSON. lookup resuelve las
+// variables ${...} de los upstreams, normalmente os.Getenv con valores por defecto.
+func Load(path string, lookup func(string) string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var table Table
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&table); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := table.normalize(lookup); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &table, nil
+}
+
+// normalize expande los upstreams, aplica los valores por defecto y valida cada ruta
+func (t *Table) normalize(lookup func(string) string) error {
+	if len(t.Routes) == 0 {
+		return fmt.Errorf("no hay rutas definidas")
+	}
+	for name, base := range t.Upstreams {
+		base = strings.TrimSuffix(os.Expand(base, lookup), "/")
+		parsed, err := url.Parse(base)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("upstream %s: URL inválida %q", name, base)
+		}
+		t.Upstreams[name] = base
+	}
+	if t.DefaultRateLimit != nil {
+		if err := t.DefaultRateLimit.validate(); err != nil {
+			return fmt.Errorf("default_%w", err)
+		}
+	}
+	for i := range t.Routes {
+		route := &t.Routes[i]
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("ruta %d: path debe empezar por /", i)
+		}
+		// Los endpoints internos solo los llama el Gateway; nunca se publican
+		if strings.HasPrefix(route.Path, internalPathPrefix) {
+			return fmt.Errorf("ruta %s: los endpoints internos no se pueden publicar", route.Path)
+		}
+		if _, ok := t.Upstreams[route.Upstream]; !ok {
+			return fmt.Errorf("ruta %s: upstream desconocido %q", route.Path, route.Upstream)
+		}
+		if len(route.Methods) == 0 {
+			route.Methods = []string{http.MethodGet}
+		}
+		for j, method := range route.Methods {
+			route.Methods[j] = strings.ToUpper(method)
+		}
+		if route.Timeout.Duration <= 0 {
+			route.Timeout.Duration = defaultTimeout
+		}
+		if !route.RequiresAuth() && len(route.Scopes) > 0 {
+			return fmt.Errorf("ruta %s: los scopes requieren auth", route.Path)
+		}
+		if route.RateLimit != nil {
+			if err := route.RateLimit.validate(); err != nil {
+				return fmt.Errorf("ruta %s: %w", route.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UpstreamURL devuelve la URL base del upstream de la ruta
+func (t *Table) UpstreamURL(route Route) string {
+	return t.Upstreams[route.Upstream]
+}
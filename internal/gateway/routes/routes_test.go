@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRejectsInternalRoutes(t *testing.T) {
+	table := Table{
+		Upstreams: map[string]string{"auth": "http://auth:8081"},
+		Routes:    []Route{{Path: "/api/v1/internal/refund", Upstream: "auth"}},
+	}
+	err := table.normalize(os.Getenv)
+	if err == nil || !strings.Contains(err.Error(), "internos") {
+		t.Fatalf("normalize = %v, se esperaba un error", err)
+	}
+}
+
+func TestNormalizeExpandsUpstreams(t *testing.T) {
+	table := Table{
+		Upstreams: map[string]string{"rickmorty": "http://rickmorty:${RICKMORTY_SERVICE_PORT}/"},
+		Routes:    []Route{{Path: "/api/v1/characters", Upstream: "rickmorty"}},
+	}
+	lookup := func(name string) string {
+		if name == "RICKMORTY_SERVICE_PORT" {
+			return "9090"
+		}
+		return ""
+	}
+	if err := table.normalize(lookup); err != nil {
+		t.Fatal(err)
+	}
+	if got := table.Upstreams["rickmorty"]; got != "http://rickmorty:9090" {
+		t.Fatalf("upstream = %q, se esperaba http://rickmorty:9090", got)
+	}
+}
+
+func TestLoadRepositoryRoutes(t *testing.T) {
+	ports := map[string]string{"AUTH_SERVICE_PORT": "8081", "RICKMORTY_SERVICE_PORT": "8082"}
+	table, err := Load("../../../config/routes.json", func(name string) string { return ports[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, base := range table.Upstreams {
+		if strings.Contains(base, "$") || strings.HasSuffix(base, ":") {
+			t.Errorf("upstream %s sin expandir: %q", name, base)
+		}
+	}
+	for _, route := range table.Routes {
+		if route.Upstream == "auth" && route.RequiresAuth() {
+			// El servicio de autenticación valida sus propias credenciales;
+			// pasar por /validate cobraría la petición
+			t.Errorf("ruta %s: las rutas de auth no deben exigir auth en el Gateway", route.Path)
+		}
+	}
+}
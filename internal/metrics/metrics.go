@@ -0,0 +1,261 @@
+// Package metrics implementa las métricas de los servicios y las expone en el
+// formato de texto de Prometheus. Cada servicio declara sus métricas con
+// NewCounter, NewHistogram y NewGaugeFunc y las publica con Serve en un
+// puerto interno, separado del que atiende a los clientes.
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets son los límites, en segundos, de los histogramas de latencia
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric es una familia de series que sabe escribirse en formato de texto
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, existing := range registry {
+		if existing.name() == m.name() {
+			panic("metrics: métrica duplicada " + m.name())
+		}
+	}
+	registry = append(registry, m)
+}
+
+// Counter es un contador con etiquetas
+type Counter struct {
+	metricName string
+	help       string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{metricName: name, help: help, labels: labels, values: map[string]*counterValue{}}
+	register(c)
+	return c
+}
+
+// Inc suma uno a la serie con los valores de etiqueta dados, en el orden en
+// que se declararon las etiquetas
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	key := seriesKey(c.metricName, c.labels, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.values[key]
+	if !ok {
+		entry = &counterValue{labels: labelValues}
+		c.values[key] = entry
+	}
+	entry.value += v
+}
+
+func (c *Counter) name() string { return c.metricName }
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		entry := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, formatLabels(c.labels, entry.labels), formatValue(entry.value))
+	}
+}
+
+// Histogram cuenta observaciones en buckets acumulativos
+type Histogram struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	values     map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{metricName: name, help: help, labels: labels, buckets: buckets, values: map[string]*histogramValue{}}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := seriesKey(h.metricName, h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogramValue{labels: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = entry
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			entry.counts[i]++
+		}
+	}
+	entry.sum += v
+	entry.count++
+}
+
+// ObserveDuration registra una duración en segundos
+func (h *Histogram) ObserveDuration(d time.Duration, labelValues ...string) {
+	h.Observe(d.Seconds(), labelValues...)
+}
+
+func (h *Histogram) name() string { return h.metricName }
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.metricName, h.help, "histogram")
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		entry := h.values[key]
+		for i, bound := range h.buckets {
+			values := append(append([]string(nil), entry.labels...), formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketLabels, values), entry.counts[i])
+		}
+		values := append(append([]string(nil), entry.labels...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(bucketLabels, values), entry.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, entry.labels), formatValue(entry.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, entry.labels), entry.count)
+	}
+}
+
+// GaugeFunc es un gauge cuyo valor se calcula al exponer las métricas
+type GaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) name() string { return g.metricName }
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.fn()))
+}
+
+// Handler expone todas las métricas en el formato de texto de Prometheus. Con
+// METRICS_TOKEN definido exige "Authorization: Bearer <METRICS_TOKEN>".
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := os.Getenv("METRICS_TOKEN"); token != "" {
+			given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				http.Error(w, "no autorizado", http.StatusUnauthorized)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+// Serve publica Handler en /metrics en su propio listener. El puerto no se
+// publica fuera de la red interna, así que las métricas no quedan al alcance
+// de los clientes aunque no se defina METRICS_TOKEN.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, mux)
+}
+
+// WriteText escribe todas las métricas registradas
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	registryMu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+func seriesKey(name string, labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: %s espera %d etiquetas y recibió %d", name, len(labels), len(values)))
+	}
+	return strings.Join(values, "\x00")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
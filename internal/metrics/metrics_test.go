@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerToken(t *testing.T) {
+	requests := NewCounter("test_requests_total", "Peticiones de prueba", "status")
+	requests.Inc("200")
+	requests.Add(2, "500")
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{"sin token configurado", "", "", 200},
+		{"token correcto", "s3cret", "Bearer s3cret", 200},
+		{"sin cabecera", "s3cret", "", 401},
+		{"token incorrecto", "s3cret", "Bearer otro", 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METRICS_TOKEN", tt.token)
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			Handler().ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, se esperaba %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == 200 && !strings.Contains(w.Body.String(), `test_requests_total{status="500"} 2`) {
+				t.Fatalf("cuerpo sin la serie:\n%s", w.Body.String())
+			}
+		})
+	}
+}
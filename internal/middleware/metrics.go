@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("http_requests_total",
+		"Peticiones HTTP atendidas por ruta, método y estado", "route", "method", "status")
+	httpDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"Duración de las peticiones HTTP por ruta, método y estado", metrics.DefaultBuckets, "route", "method", "status")
+	upstreamDuration = metrics.NewHistogram("upstream_request_duration_seconds",
+		"Duración de las llamadas a otros servicios", metrics.DefaultBuckets, "upstream")
+)
+
+// unmatchedRoute agrupa las peticiones que no coinciden con ninguna ruta, para
+// que las URL arbitrarias no creen series nuevas
+const unmatchedRoute = "unmatched"
+
+// Route es un middleware de mux que anota la plantilla de la ruta
+// (p. ej. /api/v1/character/{id}) para las métricas y el log de acceso
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				if entry, ok := r.Context().Value(requestLogKey).(*requestLog); ok {
+					entry.mu.Lock()
+					entry.route = template
+					entry.mu.Unlock()
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// observeRequest alimenta las métricas HTTP con una petición terminada
+func observeRequest(route, method string, status int, latency time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		method = "OTHER"
+	}
+	code := strconv.Itoa(status)
+	httpRequests.Inc(route, method, code)
+	httpDuration.ObserveDuration(latency, route, method, code)
+}
@@ -0,0 +1,182 @@
+// Package middleware contiene los middlewares HTTP comunes a los tres
+// servicios: el identificador de petición (X-Request-ID), el log de acceso
+// estructurado en JSON con log/slog y las métricas HTTP.
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader es la cabecera con la que se propaga el identificador
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limita los identificadores aceptados del cliente para que no
+// se puedan inyectar valores arbitrarios en los logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	requestLogKey
+)
+
+// NewLogger crea el logger JSON del servicio
+func NewLogger(service string) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil)).With("service", service)
+}
+
+// RequestID acepta el X-Request-ID de la petición o genera uno nuevo. Queda
+// en el contexto, en la cabecera de la petición (así el proxy del Gateway lo
+// reenvía a los upstreams) y en la respuesta.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func newRequestID() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return hex.EncodeToString(random)
+}
+
+// RequestIDFromContext devuelve el identificador de la petición en curso
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestLog acumula los datos que los handlers aportan al log de acceso
+type requestLog struct {
+	mu        sync.Mutex
+	route     string
+	user      string
+	upstreams []slog.Attr
+}
+
+// SetUser registra el usuario autenticado de la petición
+func SetUser(ctx context.Context, user string) {
+	if entry, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		entry.mu.Lock()
+		entry.user = user
+		entry.mu.Unlock()
+	}
+}
+
+// RecordUpstream registra cuánto tardó en responder un upstream
+func RecordUpstream(ctx context.Context, name string, latency time.Duration) {
+	if entry, ok := ctx.Value(requestLogKey).(*requestLog); ok {
+		entry.mu.Lock()
+		entry.upstreams = append(entry.upstreams, slog.Float64(name, milliseconds(latency)))
+		entry.mu.Unlock()
+	}
+	upstreamDuration.ObserveDuration(latency, name)
+}
+
+// AccessLog escribe una línea JSON por petición con el método, la ruta, el
+// estado, la duración, los bytes enviados, el usuario y la latencia de cada
+// upstream consultado, y registra la petición en las métricas HTTP
+func AccessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &requestLog{}
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestLogKey, entry)))
+		latency := time.Since(start)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		entry.mu.Lock()
+		attrs := []slog.Attr{
+			slog.String("request_id", RequestIDFromContext(r.Context())),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", recorder.status),
+			slog.Float64("latency_ms", milliseconds(latency)),
+			slog.Int64("bytes", recorder.bytes),
+			slog.String("remote_ip", remoteIP(r)),
+		}
+		if entry.route != "" {
+			attrs = append(attrs, slog.String("route", entry.route))
+		}
+		if entry.user != "" {
+			attrs = append(attrs, slog.String("user", entry.user))
+		}
+		if len(entry.upstreams) > 0 {
+			attrs = append(attrs, slog.Attr{Key: "upstream_latency_ms", Value: slog.GroupValue(entry.upstreams...)})
+		}
+		route := entry.route
+		entry.mu.Unlock()
+		observeRequest(route, r.Method, recorder.status, latency)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder guarda el estado y los bytes de la respuesta. Implementa
+// Unwrap para que http.ResponseController, que usa el proxy del Gateway para
+// el streaming, llegue al ResponseWriter original.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
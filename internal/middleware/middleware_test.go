@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"sin cabecera", "", false},
+		{"identificador válido", "abc-123.def:456", true},
+		{"con salto de línea", "abc\ninyectado", false},
+		{"demasiado largo", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen, forwarded string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+				forwarded = r.Header.Get(RequestIDHeader)
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if seen == "" || seen != forwarded || seen != w.Header().Get(RequestIDHeader) {
+				t.Fatalf("contexto %q, petición %q, respuesta %q", seen, forwarded, w.Header().Get(RequestIDHeader))
+			}
+			if (seen == tt.incoming) != tt.keep {
+				t.Fatalf("identificador = %q", seen)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := RequestID(AccessLog(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r.Context(), "rick")
+		RecordUpstream(r.Context(), "rickmorty", 12*time.Millisecond)
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("fallo"))
+	})))
+	r := httptest.NewRequest("GET", "/api/v1/characters", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("línea de log inválida %q: %v", buf.String(), err)
+	}
+	upstreams, _ := line["upstream_latency_ms"].(map[string]interface{})
+	if line["level"] != "ERROR" || line["request_id"] != "req-1" || line["status"] != float64(502) ||
+		line["bytes"] != float64(5) || line["user"] != "rick" || line["path"] != "/api/v1/characters" ||
+		upstreams["rickmorty"] != float64(12) {
+		t.Fatalf("línea de log = %v", line)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/yourusername/api_ricky_and_morty/internal/middleware"
 )
 
 const baseURL = "https://rickandmortyapi.com/api"
@@ -117,7 +119,9 @@ func (h *RickMortyHandler) ProxyHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Realizar la petición
+	start := time.Now()
 	resp, err := client.Do(req)
+	middleware.RecordUpstream(r.Context(), "rickandmortyapi", time.Since(start))
 	if err != nil {
 		sendJSONResponse(w, http.StatusBadGateway, "error", "Error consultando la API pública: "+err.Error(), nil)
 		return
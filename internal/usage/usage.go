@@ -0,0 +1,14 @@
+// Package usage define el contrato de /internal/usage que comparten el Gateway,
+// que envía los lotes de peticiones validadas localmente, y el servicio de
+// autenticación, que las contabiliza.
+package usage
+
+// Límites de cada lote. El servicio de autenticación cobra cada petición en su
+// propia transacción, así que un lote desmedido bloquearía la base de datos;
+// el Gateway reparte lo que sobra entre los lotes siguientes.
+const (
+	// MaxBatchEntries es el máximo de tokens distintos por lote
+	MaxBatchEntries = 1000
+	// MaxBatchCount es el máximo de peticiones de un mismo token por lote
+	MaxBatchCount = 1000
+)